@@ -25,12 +25,22 @@ type Metadata struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// 原始的URL
 	OriginalUrl string `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
-	// 生成短链的有效期，单位：7天/15天/30天
+	// 生成短链的有效期，单位：天，兼容旧版本，指定了expiry时忽略
 	Expiration int64 `protobuf:"varint,2,opt,name=expiration,proto3" json:"expiration,omitempty"`
 	// 自定义短码，可选
 	CustomCode *string `protobuf:"bytes,3,opt,name=custom_code,json=customCode,proto3,oneof" json:"custom_code,omitempty"`
 	// 备注
-	Comment       string `protobuf:"bytes,4,opt,name=comment,proto3" json:"comment,omitempty"`
+	Comment string `protobuf:"bytes,4,opt,name=comment,proto3" json:"comment,omitempty"`
+	// 客户端幂等键，可选，保留期内使用相同幂等键的请求返回同一条短链
+	IdempotencyKey *string `protobuf:"bytes,5,opt,name=idempotency_key,json=idempotencyKey,proto3,oneof" json:"idempotency_key,omitempty"`
+	// 过期方式，都不指定时使用业务的默认有效期
+	//
+	// Types that are valid to be assigned to Expiry:
+	//
+	//	*Metadata_ExpireAt
+	//	*Metadata_TtlSeconds
+	//	*Metadata_NeverExpire
+	Expiry        isMetadata_Expiry `protobuf_oneof:"expiry"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -93,6 +103,72 @@ func (x *Metadata) GetComment() string {
 	return ""
 }
 
+func (x *Metadata) GetIdempotencyKey() string {
+	if x != nil && x.IdempotencyKey != nil {
+		return *x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *Metadata) GetExpiry() isMetadata_Expiry {
+	if x != nil {
+		return x.Expiry
+	}
+	return nil
+}
+
+func (x *Metadata) GetExpireAt() int64 {
+	if x != nil {
+		if x, ok := x.Expiry.(*Metadata_ExpireAt); ok {
+			return x.ExpireAt
+		}
+	}
+	return 0
+}
+
+func (x *Metadata) GetTtlSeconds() int64 {
+	if x != nil {
+		if x, ok := x.Expiry.(*Metadata_TtlSeconds); ok {
+			return x.TtlSeconds
+		}
+	}
+	return 0
+}
+
+func (x *Metadata) GetNeverExpire() bool {
+	if x != nil {
+		if x, ok := x.Expiry.(*Metadata_NeverExpire); ok {
+			return x.NeverExpire
+		}
+	}
+	return false
+}
+
+type isMetadata_Expiry interface {
+	isMetadata_Expiry()
+}
+
+type Metadata_ExpireAt struct {
+	// 绝对过期时间，毫秒时间戳
+	ExpireAt int64 `protobuf:"varint,6,opt,name=expire_at,json=expireAt,proto3,oneof"`
+}
+
+type Metadata_TtlSeconds struct {
+	// 有效期，单位：秒
+	TtlSeconds int64 `protobuf:"varint,7,opt,name=ttl_seconds,json=ttlSeconds,proto3,oneof"`
+}
+
+type Metadata_NeverExpire struct {
+	// 永不过期
+	NeverExpire bool `protobuf:"varint,8,opt,name=never_expire,json=neverExpire,proto3,oneof"`
+}
+
+func (*Metadata_ExpireAt) isMetadata_Expiry() {}
+
+func (*Metadata_TtlSeconds) isMetadata_Expiry() {}
+
+func (*Metadata_NeverExpire) isMetadata_Expiry() {}
+
 type URLRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// 所属业务
@@ -222,7 +298,7 @@ type URLResponseContent struct {
 	OriginalUrl string `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
 	// 生成的短码
 	ShortCode string `protobuf:"bytes,2,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
-	// 过期时间
+	// 过期时间，毫秒时间戳，0表示永不过期
 	ExpireAt      int64 `protobuf:"varint,3,opt,name=expire_at,json=expireAt,proto3" json:"expire_at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -343,10 +419,13 @@ func (x *BatchURLRequest) GetCreator() string {
 }
 
 type BatchURLResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Resp          []*URLResponseContent  `protobuf:"bytes,1,rep,name=resp,proto3" json:"resp,omitempty"`
-	StatusCode    int64                  `protobuf:"varint,2,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 生成成功的短链列表
+	Resp       []*URLResponseContent `protobuf:"bytes,1,rep,name=resp,proto3" json:"resp,omitempty"`
+	StatusCode int64                 `protobuf:"varint,2,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	Message    string                `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// 每条URL的处理结果，与请求中meta的顺序一致
+	Results       []*BatchURLResult `protobuf:"bytes,4,rep,name=results,proto3" json:"results,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -402,6 +481,85 @@ func (x *BatchURLResponse) GetMessage() string {
 	return ""
 }
 
+func (x *BatchURLResponse) GetResults() []*BatchURLResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BatchURLResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 对应请求中meta列表的下标
+	Index int64 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// 生成成功时的短链信息
+	Content *URLResponseContent `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	// 单条的状态码
+	StatusCode int64 `protobuf:"varint,3,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	// 单条的处理消息，失败时为失败原因
+	Message       string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchURLResult) Reset() {
+	*x = BatchURLResult{}
+	mi := &file_generate_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchURLResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchURLResult) ProtoMessage() {}
+
+func (x *BatchURLResult) ProtoReflect() protoreflect.Message {
+	mi := &file_generate_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchURLResult.ProtoReflect.Descriptor instead.
+func (*BatchURLResult) Descriptor() ([]byte, []int) {
+	return file_generate_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BatchURLResult) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *BatchURLResult) GetContent() *URLResponseContent {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *BatchURLResult) GetStatusCode() int64 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *BatchURLResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 type UpdateURLRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// 所属业务
@@ -418,7 +576,7 @@ type UpdateURLRequest struct {
 
 func (x *UpdateURLRequest) Reset() {
 	*x = UpdateURLRequest{}
-	mi := &file_generate_proto_msgTypes[6]
+	mi := &file_generate_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -430,7 +588,7 @@ func (x *UpdateURLRequest) String() string {
 func (*UpdateURLRequest) ProtoMessage() {}
 
 func (x *UpdateURLRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_generate_proto_msgTypes[6]
+	mi := &file_generate_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -443,7 +601,7 @@ func (x *UpdateURLRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateURLRequest.ProtoReflect.Descriptor instead.
 func (*UpdateURLRequest) Descriptor() ([]byte, []int) {
-	return file_generate_proto_rawDescGZIP(), []int{6}
+	return file_generate_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *UpdateURLRequest) GetBiz() string {
@@ -488,7 +646,7 @@ type DelRequest struct {
 
 func (x *DelRequest) Reset() {
 	*x = DelRequest{}
-	mi := &file_generate_proto_msgTypes[7]
+	mi := &file_generate_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -500,7 +658,7 @@ func (x *DelRequest) String() string {
 func (*DelRequest) ProtoMessage() {}
 
 func (x *DelRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_generate_proto_msgTypes[7]
+	mi := &file_generate_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -513,7 +671,7 @@ func (x *DelRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DelRequest.ProtoReflect.Descriptor instead.
 func (*DelRequest) Descriptor() ([]byte, []int) {
-	return file_generate_proto_rawDescGZIP(), []int{7}
+	return file_generate_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *DelRequest) GetBiz() string {
@@ -549,7 +707,7 @@ type DelResponse struct {
 
 func (x *DelResponse) Reset() {
 	*x = DelResponse{}
-	mi := &file_generate_proto_msgTypes[8]
+	mi := &file_generate_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -561,7 +719,7 @@ func (x *DelResponse) String() string {
 func (*DelResponse) ProtoMessage() {}
 
 func (x *DelResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_generate_proto_msgTypes[8]
+	mi := &file_generate_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -574,7 +732,7 @@ func (x *DelResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DelResponse.ProtoReflect.Descriptor instead.
 func (*DelResponse) Descriptor() ([]byte, []int) {
-	return file_generate_proto_rawDescGZIP(), []int{8}
+	return file_generate_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *DelResponse) GetCode() int64 {
@@ -591,20 +749,367 @@ func (x *DelResponse) GetMessage() string {
 	return ""
 }
 
+type LookupByURLRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 原始URL
+	OriginalUrl string `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	// 所属业务，为空时查询所有业务
+	Biz           string `protobuf:"bytes,2,opt,name=biz,proto3" json:"biz,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupByURLRequest) Reset() {
+	*x = LookupByURLRequest{}
+	mi := &file_generate_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupByURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupByURLRequest) ProtoMessage() {}
+
+func (x *LookupByURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_generate_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupByURLRequest.ProtoReflect.Descriptor instead.
+func (*LookupByURLRequest) Descriptor() ([]byte, []int) {
+	return file_generate_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *LookupByURLRequest) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *LookupByURLRequest) GetBiz() string {
+	if x != nil {
+		return x.Biz
+	}
+	return ""
+}
+
+type LookupByURLResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 状态码
+	StatusCode int64 `protobuf:"varint,1,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	// 消息
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// 指向原始URL的所有短链
+	Codes         []*ShortCodeDetail `protobuf:"bytes,3,rep,name=codes,proto3" json:"codes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupByURLResponse) Reset() {
+	*x = LookupByURLResponse{}
+	mi := &file_generate_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupByURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupByURLResponse) ProtoMessage() {}
+
+func (x *LookupByURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_generate_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupByURLResponse.ProtoReflect.Descriptor instead.
+func (*LookupByURLResponse) Descriptor() ([]byte, []int) {
+	return file_generate_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *LookupByURLResponse) GetStatusCode() int64 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *LookupByURLResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *LookupByURLResponse) GetCodes() []*ShortCodeDetail {
+	if x != nil {
+		return x.Codes
+	}
+	return nil
+}
+
+type ShortCodeDetail struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// URL所属ID
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// 所属业务
+	Biz string `protobuf:"bytes,2,opt,name=biz,proto3" json:"biz,omitempty"`
+	// 原始的URL
+	OriginalUrl string `protobuf:"bytes,3,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	// 短码
+	ShortCode string `protobuf:"bytes,4,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	// 过期时间
+	ExpireAt int64 `protobuf:"varint,5,opt,name=expire_at,json=expireAt,proto3" json:"expire_at,omitempty"`
+	// 创建者
+	Creator string `protobuf:"bytes,6,opt,name=creator,proto3" json:"creator,omitempty"`
+	// 备注
+	Comment string `protobuf:"bytes,7,opt,name=comment,proto3" json:"comment,omitempty"`
+	// 创建时间
+	CreateTime    int64 `protobuf:"varint,8,opt,name=create_time,json=createTime,proto3" json:"create_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShortCodeDetail) Reset() {
+	*x = ShortCodeDetail{}
+	mi := &file_generate_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShortCodeDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShortCodeDetail) ProtoMessage() {}
+
+func (x *ShortCodeDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_generate_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShortCodeDetail.ProtoReflect.Descriptor instead.
+func (*ShortCodeDetail) Descriptor() ([]byte, []int) {
+	return file_generate_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ShortCodeDetail) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ShortCodeDetail) GetBiz() string {
+	if x != nil {
+		return x.Biz
+	}
+	return ""
+}
+
+func (x *ShortCodeDetail) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *ShortCodeDetail) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ShortCodeDetail) GetExpireAt() int64 {
+	if x != nil {
+		return x.ExpireAt
+	}
+	return 0
+}
+
+func (x *ShortCodeDetail) GetCreator() string {
+	if x != nil {
+		return x.Creator
+	}
+	return ""
+}
+
+func (x *ShortCodeDetail) GetComment() string {
+	if x != nil {
+		return x.Comment
+	}
+	return ""
+}
+
+func (x *ShortCodeDetail) GetCreateTime() int64 {
+	if x != nil {
+		return x.CreateTime
+	}
+	return 0
+}
+
+type ResolveRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 短码
+	ShortCode string `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	// 所属业务，业务使用独占的分库分表时需要指定
+	Biz           string `protobuf:"bytes,2,opt,name=biz,proto3" json:"biz,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveRequest) Reset() {
+	*x = ResolveRequest{}
+	mi := &file_generate_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveRequest) ProtoMessage() {}
+
+func (x *ResolveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_generate_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveRequest.ProtoReflect.Descriptor instead.
+func (*ResolveRequest) Descriptor() ([]byte, []int) {
+	return file_generate_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ResolveRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetBiz() string {
+	if x != nil {
+		return x.Biz
+	}
+	return ""
+}
+
+type ResolveResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 短链信息
+	Resp          *URLResponseContent `protobuf:"bytes,1,opt,name=resp,proto3" json:"resp,omitempty"`
+	StatusCode    int64               `protobuf:"varint,2,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	Message       string              `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveResponse) Reset() {
+	*x = ResolveResponse{}
+	mi := &file_generate_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveResponse) ProtoMessage() {}
+
+func (x *ResolveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_generate_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveResponse.ProtoReflect.Descriptor instead.
+func (*ResolveResponse) Descriptor() ([]byte, []int) {
+	return file_generate_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ResolveResponse) GetResp() *URLResponseContent {
+	if x != nil {
+		return x.Resp
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetStatusCode() int64 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *ResolveResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 var File_generate_proto protoreflect.FileDescriptor
 
 const file_generate_proto_rawDesc = "" +
 	"\n" +
-	"\x0egenerate.proto\x12\aintr.v1\"\x9d\x01\n" +
+	"\x0egenerate.proto\x12\aintr.v1\"\xd0\x02\n" +
 	"\bMetadata\x12!\n" +
 	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\x12\x1e\n" +
 	"\n" +
 	"expiration\x18\x02 \x01(\x03R\n" +
 	"expiration\x12$\n" +
-	"\vcustom_code\x18\x03 \x01(\tH\x00R\n" +
+	"\vcustom_code\x18\x03 \x01(\tH\x01R\n" +
 	"customCode\x88\x01\x01\x12\x18\n" +
-	"\acomment\x18\x04 \x01(\tR\acommentB\x0e\n" +
-	"\f_custom_code\"_\n" +
+	"\acomment\x18\x04 \x01(\tR\acomment\x12,\n" +
+	"\x0fidempotency_key\x18\x05 \x01(\tH\x02R\x0eidempotencyKey\x88\x01\x01\x12\x1d\n" +
+	"\texpire_at\x18\x06 \x01(\x03H\x00R\bexpireAt\x12!\n" +
+	"\vttl_seconds\x18\a \x01(\x03H\x00R\n" +
+	"ttlSeconds\x12#\n" +
+	"\fnever_expire\x18\b \x01(\bH\x00R\vneverExpireB\b\n" +
+	"\x06expiryB\x0e\n" +
+	"\f_custom_codeB\x12\n" +
+	"\x10_idempotency_key\"_\n" +
 	"\n" +
 	"URLRequest\x12\x10\n" +
 	"\x03biz\x18\x01 \x01(\tR\x03biz\x12%\n" +
@@ -623,12 +1128,19 @@ const file_generate_proto_rawDesc = "" +
 	"\x0fBatchURLRequest\x12\x10\n" +
 	"\x03biz\x18\x01 \x01(\tR\x03biz\x12%\n" +
 	"\x04meta\x18\x02 \x03(\v2\x11.intr.v1.MetadataR\x04meta\x12\x18\n" +
-	"\acreator\x18\x03 \x01(\tR\acreator\"~\n" +
+	"\acreator\x18\x03 \x01(\tR\acreator\"\xb1\x01\n" +
 	"\x10BatchURLResponse\x12/\n" +
 	"\x04resp\x18\x01 \x03(\v2\x1b.intr.v1.URLResponseContentR\x04resp\x12\x1f\n" +
 	"\vstatus_code\x18\x02 \x01(\x03R\n" +
 	"statusCode\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage\"u\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x121\n" +
+	"\aresults\x18\x04 \x03(\v2\x17.intr.v1.BatchURLResultR\aresults\"\x98\x01\n" +
+	"\x0eBatchURLResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x03R\x05index\x125\n" +
+	"\acontent\x18\x02 \x01(\v2\x1b.intr.v1.URLResponseContentR\acontent\x12\x1f\n" +
+	"\vstatus_code\x18\x03 \x01(\x03R\n" +
+	"statusCode\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\"u\n" +
 	"\x10UpdateURLRequest\x12\x10\n" +
 	"\x03biz\x18\x01 \x01(\tR\x03biz\x12\x0e\n" +
 	"\x02id\x18\x02 \x01(\x03R\x02id\x12%\n" +
@@ -641,12 +1153,42 @@ const file_generate_proto_rawDesc = "" +
 	"\x03url\x18\x03 \x01(\tR\x03url\";\n" +
 	"\vDelResponse\x12\x12\n" +
 	"\x04code\x18\x01 \x01(\x03R\x04code\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2\x82\x02\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"I\n" +
+	"\x12LookupByURLRequest\x12!\n" +
+	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\x12\x10\n" +
+	"\x03biz\x18\x02 \x01(\tR\x03biz\"\x80\x01\n" +
+	"\x13LookupByURLResponse\x12\x1f\n" +
+	"\vstatus_code\x18\x01 \x01(\x03R\n" +
+	"statusCode\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12.\n" +
+	"\x05codes\x18\x03 \x03(\v2\x18.intr.v1.ShortCodeDetailR\x05codes\"\xe7\x01\n" +
+	"\x0fShortCodeDetail\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x10\n" +
+	"\x03biz\x18\x02 \x01(\tR\x03biz\x12!\n" +
+	"\foriginal_url\x18\x03 \x01(\tR\voriginalUrl\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x04 \x01(\tR\tshortCode\x12\x1b\n" +
+	"\texpire_at\x18\x05 \x01(\x03R\bexpireAt\x12\x18\n" +
+	"\acreator\x18\x06 \x01(\tR\acreator\x12\x18\n" +
+	"\acomment\x18\a \x01(\tR\acomment\x12\x1f\n" +
+	"\vcreate_time\x18\b \x01(\x03R\n" +
+	"createTime\"A\n" +
+	"\x0eResolveRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x10\n" +
+	"\x03biz\x18\x02 \x01(\tR\x03biz\"}\n" +
+	"\x0fResolveResponse\x12/\n" +
+	"\x04resp\x18\x01 \x01(\v2\x1b.intr.v1.URLResponseContentR\x04resp\x12\x1f\n" +
+	"\vstatus_code\x18\x02 \x01(\x03R\n" +
+	"statusCode\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage2\x99\x03\n" +
 	"\tGenerator\x12:\n" +
 	"\vGenerateURL\x12\x13.intr.v1.URLRequest\x1a\x14.intr.v1.URLResponse\"\x00\x12I\n" +
-	"\x10BatchGenerateURL\x12\x18.intr.v1.BatchURLRequest\x1a\x19.intr.v1.BatchURLResponse\"\x00\x126\n" +
-	"\tUpdateURL\x12\x13.intr.v1.URLRequest\x1a\x14.intr.v1.URLResponse\x126\n" +
-	"\tDeleteURL\x12\x13.intr.v1.DelRequest\x1a\x14.intr.v1.DelResponseB\x10Z\x0eintr.v1;intrv1b\x06proto3"
+	"\x10BatchGenerateURL\x12\x18.intr.v1.BatchURLRequest\x1a\x19.intr.v1.BatchURLResponse\"\x00\x12<\n" +
+	"\tUpdateURL\x12\x19.intr.v1.UpdateURLRequest\x1a\x14.intr.v1.URLResponse\x126\n" +
+	"\tDeleteURL\x12\x13.intr.v1.DelRequest\x1a\x14.intr.v1.DelResponse\x12H\n" +
+	"\vLookupByURL\x12\x1b.intr.v1.LookupByURLRequest\x1a\x1c.intr.v1.LookupByURLResponse\x12E\n" +
+	"\x10ResolveShortCode\x12\x17.intr.v1.ResolveRequest\x1a\x18.intr.v1.ResolveResponseB\x10Z\x0eintr.v1;intrv1b\x06proto3"
 
 var (
 	file_generate_proto_rawDescOnce sync.Once
@@ -660,37 +1202,51 @@ func file_generate_proto_rawDescGZIP() []byte {
 	return file_generate_proto_rawDescData
 }
 
-var file_generate_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_generate_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_generate_proto_goTypes = []any{
-	(*Metadata)(nil),           // 0: intr.v1.Metadata
-	(*URLRequest)(nil),         // 1: intr.v1.URLRequest
-	(*URLResponse)(nil),        // 2: intr.v1.URLResponse
-	(*URLResponseContent)(nil), // 3: intr.v1.URLResponseContent
-	(*BatchURLRequest)(nil),    // 4: intr.v1.BatchURLRequest
-	(*BatchURLResponse)(nil),   // 5: intr.v1.BatchURLResponse
-	(*UpdateURLRequest)(nil),   // 6: intr.v1.UpdateURLRequest
-	(*DelRequest)(nil),         // 7: intr.v1.DelRequest
-	(*DelResponse)(nil),        // 8: intr.v1.DelResponse
+	(*Metadata)(nil),            // 0: intr.v1.Metadata
+	(*URLRequest)(nil),          // 1: intr.v1.URLRequest
+	(*URLResponse)(nil),         // 2: intr.v1.URLResponse
+	(*URLResponseContent)(nil),  // 3: intr.v1.URLResponseContent
+	(*BatchURLRequest)(nil),     // 4: intr.v1.BatchURLRequest
+	(*BatchURLResponse)(nil),    // 5: intr.v1.BatchURLResponse
+	(*BatchURLResult)(nil),      // 6: intr.v1.BatchURLResult
+	(*UpdateURLRequest)(nil),    // 7: intr.v1.UpdateURLRequest
+	(*DelRequest)(nil),          // 8: intr.v1.DelRequest
+	(*DelResponse)(nil),         // 9: intr.v1.DelResponse
+	(*LookupByURLRequest)(nil),  // 10: intr.v1.LookupByURLRequest
+	(*LookupByURLResponse)(nil), // 11: intr.v1.LookupByURLResponse
+	(*ShortCodeDetail)(nil),     // 12: intr.v1.ShortCodeDetail
+	(*ResolveRequest)(nil),      // 13: intr.v1.ResolveRequest
+	(*ResolveResponse)(nil),     // 14: intr.v1.ResolveResponse
 }
 var file_generate_proto_depIdxs = []int32{
-	0, // 0: intr.v1.URLRequest.meta:type_name -> intr.v1.Metadata
-	3, // 1: intr.v1.URLResponse.resp:type_name -> intr.v1.URLResponseContent
-	0, // 2: intr.v1.BatchURLRequest.meta:type_name -> intr.v1.Metadata
-	3, // 3: intr.v1.BatchURLResponse.resp:type_name -> intr.v1.URLResponseContent
-	0, // 4: intr.v1.UpdateURLRequest.meta:type_name -> intr.v1.Metadata
-	1, // 5: intr.v1.Generator.GenerateURL:input_type -> intr.v1.URLRequest
-	4, // 6: intr.v1.Generator.BatchGenerateURL:input_type -> intr.v1.BatchURLRequest
-	1, // 7: intr.v1.Generator.UpdateURL:input_type -> intr.v1.URLRequest
-	7, // 8: intr.v1.Generator.DeleteURL:input_type -> intr.v1.DelRequest
-	2, // 9: intr.v1.Generator.GenerateURL:output_type -> intr.v1.URLResponse
-	5, // 10: intr.v1.Generator.BatchGenerateURL:output_type -> intr.v1.BatchURLResponse
-	2, // 11: intr.v1.Generator.UpdateURL:output_type -> intr.v1.URLResponse
-	8, // 12: intr.v1.Generator.DeleteURL:output_type -> intr.v1.DelResponse
-	9, // [9:13] is the sub-list for method output_type
-	5, // [5:9] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	0,  // 0: intr.v1.URLRequest.meta:type_name -> intr.v1.Metadata
+	3,  // 1: intr.v1.URLResponse.resp:type_name -> intr.v1.URLResponseContent
+	0,  // 2: intr.v1.BatchURLRequest.meta:type_name -> intr.v1.Metadata
+	3,  // 3: intr.v1.BatchURLResponse.resp:type_name -> intr.v1.URLResponseContent
+	6,  // 4: intr.v1.BatchURLResponse.results:type_name -> intr.v1.BatchURLResult
+	3,  // 5: intr.v1.BatchURLResult.content:type_name -> intr.v1.URLResponseContent
+	0,  // 6: intr.v1.UpdateURLRequest.meta:type_name -> intr.v1.Metadata
+	12, // 7: intr.v1.LookupByURLResponse.codes:type_name -> intr.v1.ShortCodeDetail
+	3,  // 8: intr.v1.ResolveResponse.resp:type_name -> intr.v1.URLResponseContent
+	1,  // 9: intr.v1.Generator.GenerateURL:input_type -> intr.v1.URLRequest
+	4,  // 10: intr.v1.Generator.BatchGenerateURL:input_type -> intr.v1.BatchURLRequest
+	7,  // 11: intr.v1.Generator.UpdateURL:input_type -> intr.v1.UpdateURLRequest
+	8,  // 12: intr.v1.Generator.DeleteURL:input_type -> intr.v1.DelRequest
+	10, // 13: intr.v1.Generator.LookupByURL:input_type -> intr.v1.LookupByURLRequest
+	13, // 14: intr.v1.Generator.ResolveShortCode:input_type -> intr.v1.ResolveRequest
+	2,  // 15: intr.v1.Generator.GenerateURL:output_type -> intr.v1.URLResponse
+	5,  // 16: intr.v1.Generator.BatchGenerateURL:output_type -> intr.v1.BatchURLResponse
+	2,  // 17: intr.v1.Generator.UpdateURL:output_type -> intr.v1.URLResponse
+	9,  // 18: intr.v1.Generator.DeleteURL:output_type -> intr.v1.DelResponse
+	11, // 19: intr.v1.Generator.LookupByURL:output_type -> intr.v1.LookupByURLResponse
+	14, // 20: intr.v1.Generator.ResolveShortCode:output_type -> intr.v1.ResolveResponse
+	15, // [15:21] is the sub-list for method output_type
+	9,  // [9:15] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_generate_proto_init() }
@@ -698,14 +1254,18 @@ func file_generate_proto_init() {
 	if File_generate_proto != nil {
 		return
 	}
-	file_generate_proto_msgTypes[0].OneofWrappers = []any{}
+	file_generate_proto_msgTypes[0].OneofWrappers = []any{
+		(*Metadata_ExpireAt)(nil),
+		(*Metadata_TtlSeconds)(nil),
+		(*Metadata_NeverExpire)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_generate_proto_rawDesc), len(file_generate_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -23,6 +23,8 @@ const (
 	Generator_BatchGenerateURL_FullMethodName = "/intr.v1.Generator/BatchGenerateURL"
 	Generator_UpdateURL_FullMethodName        = "/intr.v1.Generator/UpdateURL"
 	Generator_DeleteURL_FullMethodName        = "/intr.v1.Generator/DeleteURL"
+	Generator_LookupByURL_FullMethodName      = "/intr.v1.Generator/LookupByURL"
+	Generator_ResolveShortCode_FullMethodName = "/intr.v1.Generator/ResolveShortCode"
 )
 
 // GeneratorClient is the client API for Generator service.
@@ -34,9 +36,13 @@ type GeneratorClient interface {
 	// 批量生成短链
 	BatchGenerateURL(ctx context.Context, in *BatchURLRequest, opts ...grpc.CallOption) (*BatchURLResponse, error)
 	// 修改单条短链
-	UpdateURL(ctx context.Context, in *URLRequest, opts ...grpc.CallOption) (*URLResponse, error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*URLResponse, error)
 	// 删除单条短链
 	DeleteURL(ctx context.Context, in *DelRequest, opts ...grpc.CallOption) (*DelResponse, error)
+	// 查询指向原始URL的所有短链
+	LookupByURL(ctx context.Context, in *LookupByURLRequest, opts ...grpc.CallOption) (*LookupByURLResponse, error)
+	// 解析短码对应的原始URL
+	ResolveShortCode(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error)
 }
 
 type generatorClient struct {
@@ -67,7 +73,7 @@ func (c *generatorClient) BatchGenerateURL(ctx context.Context, in *BatchURLRequ
 	return out, nil
 }
 
-func (c *generatorClient) UpdateURL(ctx context.Context, in *URLRequest, opts ...grpc.CallOption) (*URLResponse, error) {
+func (c *generatorClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*URLResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(URLResponse)
 	err := c.cc.Invoke(ctx, Generator_UpdateURL_FullMethodName, in, out, cOpts...)
@@ -87,6 +93,26 @@ func (c *generatorClient) DeleteURL(ctx context.Context, in *DelRequest, opts ..
 	return out, nil
 }
 
+func (c *generatorClient) LookupByURL(ctx context.Context, in *LookupByURLRequest, opts ...grpc.CallOption) (*LookupByURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupByURLResponse)
+	err := c.cc.Invoke(ctx, Generator_LookupByURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *generatorClient) ResolveShortCode(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResolveResponse)
+	err := c.cc.Invoke(ctx, Generator_ResolveShortCode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GeneratorServer is the server API for Generator service.
 // All implementations must embed UnimplementedGeneratorServer
 // for forward compatibility.
@@ -96,9 +122,13 @@ type GeneratorServer interface {
 	// 批量生成短链
 	BatchGenerateURL(context.Context, *BatchURLRequest) (*BatchURLResponse, error)
 	// 修改单条短链
-	UpdateURL(context.Context, *URLRequest) (*URLResponse, error)
+	UpdateURL(context.Context, *UpdateURLRequest) (*URLResponse, error)
 	// 删除单条短链
 	DeleteURL(context.Context, *DelRequest) (*DelResponse, error)
+	// 查询指向原始URL的所有短链
+	LookupByURL(context.Context, *LookupByURLRequest) (*LookupByURLResponse, error)
+	// 解析短码对应的原始URL
+	ResolveShortCode(context.Context, *ResolveRequest) (*ResolveResponse, error)
 	mustEmbedUnimplementedGeneratorServer()
 }
 
@@ -115,12 +145,18 @@ func (UnimplementedGeneratorServer) GenerateURL(context.Context, *URLRequest) (*
 func (UnimplementedGeneratorServer) BatchGenerateURL(context.Context, *BatchURLRequest) (*BatchURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BatchGenerateURL not implemented")
 }
-func (UnimplementedGeneratorServer) UpdateURL(context.Context, *URLRequest) (*URLResponse, error) {
+func (UnimplementedGeneratorServer) UpdateURL(context.Context, *UpdateURLRequest) (*URLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
 }
 func (UnimplementedGeneratorServer) DeleteURL(context.Context, *DelRequest) (*DelResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteURL not implemented")
 }
+func (UnimplementedGeneratorServer) LookupByURL(context.Context, *LookupByURLRequest) (*LookupByURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupByURL not implemented")
+}
+func (UnimplementedGeneratorServer) ResolveShortCode(context.Context, *ResolveRequest) (*ResolveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveShortCode not implemented")
+}
 func (UnimplementedGeneratorServer) mustEmbedUnimplementedGeneratorServer() {}
 func (UnimplementedGeneratorServer) testEmbeddedByValue()                   {}
 
@@ -179,7 +215,7 @@ func _Generator_BatchGenerateURL_Handler(srv interface{}, ctx context.Context, d
 }
 
 func _Generator_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(URLRequest)
+	in := new(UpdateURLRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -191,7 +227,7 @@ func _Generator_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func
 		FullMethod: Generator_UpdateURL_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(GeneratorServer).UpdateURL(ctx, req.(*URLRequest))
+		return srv.(GeneratorServer).UpdateURL(ctx, req.(*UpdateURLRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -214,6 +250,42 @@ func _Generator_DeleteURL_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Generator_LookupByURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupByURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeneratorServer).LookupByURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Generator_LookupByURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeneratorServer).LookupByURL(ctx, req.(*LookupByURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Generator_ResolveShortCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeneratorServer).ResolveShortCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Generator_ResolveShortCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeneratorServer).ResolveShortCode(ctx, req.(*ResolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Generator_ServiceDesc is the grpc.ServiceDesc for Generator service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -237,6 +309,14 @@ var Generator_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteURL",
 			Handler:    _Generator_DeleteURL_Handler,
 		},
+		{
+			MethodName: "LookupByURL",
+			Handler:    _Generator_LookupByURL_Handler,
+		},
+		{
+			MethodName: "ResolveShortCode",
+			Handler:    _Generator_ResolveShortCode_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "generate.proto",
@@ -0,0 +1,296 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+)
+
+const (
+	// AlphabetBase62 标准的Base62字符集
+	AlphabetBase62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// AlphabetURLSafe URL安全的64个字符，在Base62的基础上增加'-'和'_'
+	AlphabetURLSafe = AlphabetBase62 + "-_"
+	// AlphabetNoLookAlike 去掉了容易混淆的0/O/o、1/I/l，适合需要人工抄写的场景
+	AlphabetNoLookAlike = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz"
+)
+
+const (
+	// DefaultScrambleBits 默认的混淆位宽，可以编码[0, 2^40)范围内的ID，Base62下约7个字符
+	DefaultScrambleBits = 40
+	// feistelRounds Feistel网络的轮数
+	feistelRounds = 4
+)
+
+var (
+	ErrInvalidAlphabet = errors.New("字符集至少包含2个不重复的ASCII字符")
+	ErrInvalidBits     = errors.New("混淆位宽必须是2到62之间的偶数")
+	ErrOutOfRange      = errors.New("ID超出可编码的范围")
+	ErrInvalidCode     = errors.New("短码包含字符集之外的字符")
+	ErrChecksum        = errors.New("短码校验位不匹配")
+	ErrInvalidKey      = errors.New("混淆密钥不能为0")
+	ErrNonCanonical    = errors.New("短码不是ID编码得到的规范形式")
+)
+
+// Codec 递增ID与短码之间的双向编码。默认开启混淆，ID先经过以key为密钥的Feistel网络置换，
+// 连续的ID会得到毫无规律的短码，无法通过短码推算出相邻的短码。置换是一一映射，不会产生重复短码，并且可以通过Decode还原
+type Codec struct {
+	// 字符集
+	alphabet string
+	// 字符到数值的映射，-1表示不在字符集中
+	index [256]int
+	// 进制
+	base uint64
+	// 短码的最小长度，不足时在左侧用字符集的第一个字符补齐
+	minLen int
+	// 是否在末尾追加一位校验字符
+	checksum bool
+	// 是否开启混淆
+	feistel bool
+	// 混淆密钥
+	key uint64
+	// 混淆的位宽，开启混淆时ID必须小于2^bits
+	bits uint
+}
+
+type Option func(c *Codec)
+
+// WithAlphabet 设置字符集
+func WithAlphabet(alphabet string) Option {
+	return func(c *Codec) {
+		c.alphabet = alphabet
+	}
+}
+
+// WithMinLength 设置短码的最小长度
+func WithMinLength(n int) Option {
+	return func(c *Codec) {
+		c.minLen = n
+	}
+}
+
+// WithChecksum 在短码末尾追加一位校验字符，用于快速识别输错的短码
+func WithChecksum() Option {
+	return func(c *Codec) {
+		c.checksum = true
+	}
+}
+
+// WithScrambleBits 设置混淆的位宽，所有实例必须使用相同的位宽
+func WithScrambleBits(bits uint) Option {
+	return func(c *Codec) {
+		c.bits = bits
+	}
+}
+
+// WithoutScramble 关闭ID混淆，连续的ID会得到连续的短码，只适合短码可以被枚举的场景
+func WithoutScramble() Option {
+	return func(c *Codec) {
+		c.feistel = false
+	}
+}
+
+// New 创建编码器，key是混淆密钥，所有实例必须使用相同的key，否则同一个ID会编码出不同的短码
+func New(key uint64, opts ...Option) (*Codec, error) {
+	c := &Codec{
+		alphabet: AlphabetBase62,
+		feistel:  true,
+		key:      key,
+		bits:     DefaultScrambleBits,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if len(c.alphabet) < 2 {
+		return nil, ErrInvalidAlphabet
+	}
+
+	for i := range c.index {
+		c.index[i] = -1
+	}
+
+	for i := 0; i < len(c.alphabet); i++ {
+		ch := c.alphabet[i]
+		if ch >= 0x80 || c.index[ch] != -1 {
+			return nil, ErrInvalidAlphabet
+		}
+		c.index[ch] = i
+	}
+
+	if c.feistel && c.key == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	if c.feistel && (c.bits < 2 || c.bits > 62 || c.bits%2 != 0) {
+		return nil, ErrInvalidBits
+	}
+
+	c.base = uint64(len(c.alphabet))
+	return c, nil
+}
+
+// Shuffle 使用固定的种子打乱字符集，相同的种子总是得到相同的结果
+func Shuffle(alphabet string, seed int64) string {
+	chars := []byte(alphabet)
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(chars), func(i, j int) {
+		chars[i], chars[j] = chars[j], chars[i]
+	})
+
+	return string(chars)
+}
+
+// Encode 将ID编码为短码
+func (c *Codec) Encode(id int64) (string, error) {
+	if id < 0 {
+		return "", ErrOutOfRange
+	}
+
+	n := uint64(id)
+	if c.feistel {
+		if n >= 1<<c.bits {
+			return "", ErrOutOfRange
+		}
+		n = c.scramble(n)
+	}
+
+	var digits []byte
+	for n > 0 {
+		digits = append(digits, byte(n%c.base))
+		n /= c.base
+	}
+
+	for len(digits) < c.minLen || len(digits) == 0 {
+		digits = append(digits, 0)
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(digits) + 1)
+	for i := len(digits) - 1; i >= 0; i-- {
+		builder.WriteByte(c.alphabet[digits[i]])
+	}
+
+	if c.checksum {
+		builder.WriteByte(c.alphabet[c.sum(digits)])
+	}
+
+	return builder.String(), nil
+}
+
+// Decode 将短码还原为ID，只接受Encode得到的规范形式，左侧多余的补齐字符等能解码出相同ID的其他写法返回ErrNonCanonical，
+// 保证一个ID只对应一个短码
+func (c *Codec) Decode(code string) (int64, error) {
+	origin := code
+	var check int
+	if c.checksum {
+		if len(code) < 2 {
+			return 0, ErrInvalidCode
+		}
+
+		check = c.index[code[len(code)-1]]
+		if check < 0 {
+			return 0, ErrInvalidCode
+		}
+		code = code[:len(code)-1]
+	}
+
+	if code == "" {
+		return 0, ErrInvalidCode
+	}
+
+	digits := make([]byte, len(code))
+	var n uint64
+	for i := 0; i < len(code); i++ {
+		v := c.index[code[i]]
+		if v < 0 {
+			return 0, ErrInvalidCode
+		}
+
+		next := n*c.base + uint64(v)
+		if (next-uint64(v))/c.base != n {
+			return 0, ErrOutOfRange
+		}
+		n = next
+		digits[len(code)-1-i] = byte(v)
+	}
+
+	if c.checksum && c.sum(digits) != check {
+		return 0, ErrChecksum
+	}
+
+	if c.feistel {
+		if n >= 1<<c.bits {
+			return 0, ErrOutOfRange
+		}
+		n = c.unscramble(n)
+	}
+
+	if n > 1<<63-1 {
+		return 0, ErrOutOfRange
+	}
+
+	id := int64(n)
+	if canonical, err := c.Encode(id); err != nil || canonical != origin {
+		return 0, ErrNonCanonical
+	}
+
+	return id, nil
+}
+
+// sum 计算校验位，digits按照从低位到高位的顺序，每一位乘以不同的权重，可以识别单个字符错误和相邻字符交换
+func (c *Codec) sum(digits []byte) int {
+	var s uint64
+	for i, d := range digits {
+		s += uint64(d) * uint64(i+1)
+	}
+
+	return int(s % c.base)
+}
+
+// scramble 在bits位宽内执行Feistel网络，左右各一半的位
+func (c *Codec) scramble(n uint64) uint64 {
+	half := c.bits / 2
+	mask := uint64(1)<<half - 1
+	l, r := n>>half, n&mask
+	for i := uint64(0); i < feistelRounds; i++ {
+		l, r = r, l^(c.round(r, i)&mask)
+	}
+
+	return l<<half | r
+}
+
+// unscramble scramble的逆运算，按照相反的顺序执行每一轮
+func (c *Codec) unscramble(n uint64) uint64 {
+	half := c.bits / 2
+	mask := uint64(1)<<half - 1
+	l, r := n>>half, n&mask
+	for i := uint64(feistelRounds); i > 0; i-- {
+		l, r = r^(c.round(l, i-1)&mask), l
+	}
+
+	return l<<half | r
+}
+
+// round Feistel网络的轮函数，使用splitmix64的混合步骤
+func (c *Codec) round(v uint64, i uint64) uint64 {
+	z := v + c.key + i*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
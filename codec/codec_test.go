@@ -0,0 +1,181 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "base62",
+		},
+		{
+			name: "without scramble",
+			opts: []Option{WithoutScramble()},
+		},
+		{
+			name: "url safe with min length",
+			opts: []Option{WithAlphabet(AlphabetURLSafe), WithMinLength(6)},
+		},
+		{
+			name: "no look alike with checksum",
+			opts: []Option{WithAlphabet(AlphabetNoLookAlike), WithChecksum()},
+		},
+		{
+			name: "shuffled and scrambled",
+			opts: []Option{
+				WithAlphabet(Shuffle(AlphabetBase62, 20250411)),
+				WithMinLength(7),
+				WithChecksum(),
+			},
+		},
+	}
+
+	ids := []int64{0, 1, 61, 62, 3843, 1_000_000, 1<<40 - 1}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(0x5DEECE66D, tc.opts...)
+			require.NoError(t, err)
+
+			seen := make(map[string]struct{}, len(ids))
+			for _, id := range ids {
+				code, er := c.Encode(id)
+				require.NoError(t, er)
+				assert.GreaterOrEqual(t, len(code), c.minLen)
+				seen[code] = struct{}{}
+
+				res, er := c.Decode(code)
+				require.NoError(t, er)
+				assert.Equal(t, id, res)
+			}
+			assert.Len(t, seen, len(ids))
+		})
+	}
+}
+
+func TestCodec_Scramble(t *testing.T) {
+	c, err := New(42, WithScrambleBits(20))
+	require.NoError(t, err)
+
+	// 混淆是[0, 2^20)上的一一映射
+	seen := make(map[string]struct{}, 1<<20)
+	for id := int64(0); id < 1<<20; id++ {
+		code, er := c.Encode(id)
+		require.NoError(t, er)
+		seen[code] = struct{}{}
+	}
+	assert.Len(t, seen, 1<<20)
+
+	// 连续的ID不能得到相邻的短码
+	a, _ := c.Encode(1000)
+	b, _ := c.Encode(1001)
+	assert.NotEqual(t, a[:len(a)-1], b[:len(b)-1])
+
+	_, err = c.Encode(1 << 20)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+}
+
+func TestCodec_Decode(t *testing.T) {
+	c, err := New(42, WithChecksum())
+	require.NoError(t, err)
+
+	code, err := c.Encode(123456789)
+	require.NoError(t, err)
+
+	// 交换相邻的两个字符
+	swapped := []byte(code)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	_, err = c.Decode(string(swapped))
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	_, err = c.Decode("ab*c")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	_, err = c.Decode("zzzzzzzzzzzzzzzz")
+	assert.ErrorIs(t, err, ErrOutOfRange)
+}
+
+func TestCodec_DecodeNonCanonical(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+		// 根据规范的短码构造能解码出相同ID的其他写法
+		mutate func(c *Codec, code string) string
+	}{
+		{
+			name: "leading padding",
+			mutate: func(c *Codec, code string) string {
+				return c.alphabet[:1] + code
+			},
+		},
+		{
+			name: "leading padding beyond min length",
+			opts: []Option{WithMinLength(8)},
+			mutate: func(c *Codec, code string) string {
+				return c.alphabet[:1] + code
+			},
+		},
+		{
+			name: "leading padding with checksum",
+			opts: []Option{WithoutScramble(), WithChecksum()},
+			mutate: func(c *Codec, code string) string {
+				// 补齐字符的数值为0，不影响校验位
+				return c.alphabet[:1] + code
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(42, tc.opts...)
+			require.NoError(t, err)
+
+			code, err := c.Encode(1_000_000)
+			require.NoError(t, err)
+			id, err := c.Decode(code)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1_000_000), id)
+
+			_, err = c.Decode(tc.mutate(c, code))
+			assert.ErrorIs(t, err, ErrNonCanonical)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(1, WithAlphabet("aa"))
+	assert.ErrorIs(t, err, ErrInvalidAlphabet)
+
+	_, err = New(1, WithScrambleBits(41))
+	assert.ErrorIs(t, err, ErrInvalidBits)
+
+	// 默认开启混淆，必须提供密钥
+	_, err = New(0)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	c, err := New(0, WithoutScramble())
+	require.NoError(t, err)
+	code, err := c.Encode(61)
+	require.NoError(t, err)
+	assert.Equal(t, "z", code)
+}
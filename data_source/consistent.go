@@ -0,0 +1,121 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_source
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/TimeWtr/generator"
+	"github.com/cespare/xxhash/v2"
+)
+
+// DefaultVirtualNodes 每个分表默认的虚拟节点数量，虚拟节点越多分布越均匀
+const DefaultVirtualNodes = 160
+
+// vnode 哈希环上的虚拟节点
+type vnode struct {
+	hash uint64
+	// 虚拟节点对应的分表在dsts中的下标
+	idx int
+}
+
+// consistentHashFactory 基于一致性哈希的分片算法，每个分表是哈希环上的一个节点，并映射为多个虚拟节点，
+// 库的权重就是库中分表的数量。分表按照全局顺序编号，新增的库追加在最后，已有分表的名称和虚拟节点保持不变，
+// 只有落到新分表虚拟节点上的少量数据需要迁移
+type consistentHashFactory struct {
+	// 所有的分表
+	dsts []Dst
+	// 按照哈希值排序的虚拟节点
+	ring []vnode
+	// 表前缀
+	TablePrefix string
+}
+
+// NewConsistentHashFactory 分表名称由分表在dbs中的全局位置决定，虚拟节点又由分表名称计算，
+// 所以已有的库不能调整顺序、删除或者增加分表数量，新增的库只能追加在dbs的最后，否则已有分表的名称和数据分布都会改变
+func NewConsistentHashFactory(dbs []DataSource, tablePrefix string, virtualNodes int) Factory {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+
+	f := &consistentHashFactory{
+		TablePrefix: tablePrefix,
+	}
+
+	for _, ds := range dbs {
+		for i := 0; i < ds.TableCount; i++ {
+			f.dsts = append(f.dsts, Dst{
+				DB:    ds.DB,
+				Table: fmt.Sprintf("%s%d", tablePrefix, len(f.dsts)),
+			})
+		}
+	}
+
+	f.ring = make([]vnode, 0, len(f.dsts)*virtualNodes)
+	for idx, dst := range f.dsts {
+		for i := 0; i < virtualNodes; i++ {
+			f.ring = append(f.ring, vnode{
+				hash: xxhash.Sum64String(dst.Table + "#" + strconv.Itoa(i)),
+				idx:  idx,
+			})
+		}
+	}
+
+	// 哈希值相同时按照分表的顺序排序，保证每次构建的哈希环一致
+	slices.SortFunc(f.ring, func(a, b vnode) int {
+		switch {
+		case a.hash < b.hash:
+			return -1
+		case a.hash > b.hash:
+			return 1
+		default:
+			return a.idx - b.idx
+		}
+	})
+
+	return f
+}
+
+// GetDB 分片键为短码，顺时针查找第一个虚拟节点，整数分片键转换为字符串后计算
+func (c *consistentHashFactory) GetDB(shardingKey ShardingKey) (Dst, error) {
+	if len(c.ring) == 0 {
+		return Dst{}, fmt.Errorf("%w: 一致性哈希分片没有配置分表", generator.ErrShardingFailed)
+	}
+
+	var key string
+	switch k := shardingKey.(type) {
+	case StringKey, Int64Key:
+		key = k.String()
+	default:
+		return Dst{}, unsupported(shardingKey, "一致性哈希")
+	}
+
+	h := xxhash.Sum64String(key)
+	pos := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i].hash >= h
+	})
+	if pos == len(c.ring) {
+		pos = 0
+	}
+
+	return c.dsts[c.ring[pos].idx], nil
+}
+
+func (c *consistentHashFactory) AllDst() []Dst {
+	return slices.Clone(c.dsts)
+}
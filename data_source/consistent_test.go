@@ -0,0 +1,85 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_source
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestConsistentHashFactory_Weight(t *testing.T) {
+	db1, db2 := &gorm.DB{}, &gorm.DB{}
+	f := NewConsistentHashFactory([]DataSource{
+		{DB: db1, TableCount: 12},
+		{DB: db2, TableCount: 4},
+	}, "short_code_", 0)
+	require.Len(t, f.AllDst(), 16)
+
+	const total = 100000
+	counts := make(map[*gorm.DB]int)
+	for i := 0; i < total; i++ {
+		dst, err := f.GetDB(StringKey("code" + strconv.Itoa(i)))
+		require.NoError(t, err)
+		counts[dst.DB]++
+	}
+
+	// 权重为分表数量，db1承担约3/4的数据
+	assert.InDelta(t, 0.75, float64(counts[db1])/total, 0.05)
+	assert.InDelta(t, 0.25, float64(counts[db2])/total, 0.05)
+}
+
+func TestConsistentHashFactory_AddDB(t *testing.T) {
+	db1, db2, db3 := &gorm.DB{}, &gorm.DB{}, &gorm.DB{}
+	dbs := []DataSource{
+		{DB: db1, TableCount: 8},
+		{DB: db2, TableCount: 8},
+	}
+	before := NewConsistentHashFactory(dbs, "short_code_", 0)
+	after := NewConsistentHashFactory(append(dbs, DataSource{DB: db3, TableCount: 4}), "short_code_", 0)
+
+	const total = 100000
+	moved := 0
+	for i := 0; i < total; i++ {
+		key := StringKey("code" + strconv.Itoa(i))
+		src, err := before.GetDB(key)
+		require.NoError(t, err)
+		dst, err := after.GetDB(key)
+		require.NoError(t, err)
+
+		if src != dst {
+			moved++
+			// 只会迁移到新增的库
+			assert.Equal(t, db3, dst.DB)
+		}
+	}
+
+	// 新增的分表占总数的1/5，迁移的数据也在1/5左右
+	assert.InDelta(t, 0.2, float64(moved)/total, 0.05)
+}
+
+func TestConsistentHashFactory_InvalidKey(t *testing.T) {
+	f := NewConsistentHashFactory([]DataSource{{DB: &gorm.DB{}, TableCount: 2}}, "short_code_", 0)
+	_, err := f.GetDB(TimeKey(time.Now()))
+	assert.ErrorIs(t, err, generator.ErrShardingFailed)
+
+	_, err = NewConsistentHashFactory(nil, "short_code_", 0).GetDB(StringKey("code"))
+	assert.ErrorIs(t, err, generator.ErrShardingFailed)
+}
@@ -17,13 +17,17 @@ package data_source
 import (
 	"fmt"
 	"github.com/TimeWtr/generator"
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/net/context"
 	"gorm.io/gorm"
 	"time"
 )
 
 type Factory interface {
-	// GetDB 根据分片获取链接
-	GetDB(shardingKey any) (Dst, error)
+	// GetDB 根据分片键获取链接，分片键类型不支持或者没有对应的分片时返回包装了ErrShardingFailed的错误
+	GetDB(shardingKey ShardingKey) (Dst, error)
+	// AllDst 获取所有的分库分表，用于全量扫描数据
+	AllDst() []Dst
 }
 
 // Dst 分片算法计算后落到的分片
@@ -34,6 +38,15 @@ type Dst struct {
 	Table string
 }
 
+// Owns 判断db是否是在分库上创建的会话或者开启的事务，GORM的会话和事务与创建它的链接共享配置中的连接池
+func (d Dst) Owns(db *gorm.DB) bool {
+	if db == nil || db.Config == nil || d.DB == nil || d.DB.Config == nil {
+		return false
+	}
+
+	return db.Config.ConnPool == d.DB.Config.ConnPool
+}
+
 type ShardType string
 
 const (
@@ -70,32 +83,93 @@ func NewHashDataFactory(dbs []DataSource, totalTableCount int, tablePrefix strin
 	}
 }
 
-func (d *hashDataFactory) GetDB(shardingKey any) (Dst, error) {
-	shardPos := shardingKey.(int) % d.totalTableCount
+// GetDB 整数分片键直接取模，字符串分片键使用xxhash的哈希值取模
+func (d *hashDataFactory) GetDB(shardingKey ShardingKey) (Dst, error) {
+	if d.totalTableCount <= 0 {
+		return Dst{}, fmt.Errorf("%w: 哈希分片没有配置分表", generator.ErrShardingFailed)
+	}
+
+	var shardPos int
+	switch key := shardingKey.(type) {
+	case Int64Key:
+		shardPos = int(int64(key) % int64(d.totalTableCount))
+		if shardPos < 0 {
+			shardPos += d.totalTableCount
+		}
+	case StringKey:
+		shardPos = int(xxhash.Sum64String(string(key)) % uint64(d.totalTableCount))
+	default:
+		return Dst{}, unsupported(shardingKey, "哈希")
+	}
+
 	currentPos := 0
 	for _, ds := range d.dbs {
-		// 当前的位置在当前分片区间内
-		if currentPos < currentPos+ds.TableCount {
-			tableIndex := shardPos - ds.TableCount
+		// 当前的位置在当前分片区间内，分表按照全局顺序编号
+		if shardPos < currentPos+ds.TableCount {
 			return Dst{
 				DB:    ds.DB,
-				Table: fmt.Sprintf("%s%d", d.TablePrefix, tableIndex),
+				Table: fmt.Sprintf("%s%d", d.TablePrefix, shardPos),
 			}, nil
 		}
 		currentPos += ds.TableCount
 	}
 
-	return Dst{}, generator.ErrShardingFailed
+	return Dst{}, fmt.Errorf("%w: 分表%s%d没有对应的分库", generator.ErrShardingFailed, d.TablePrefix, shardPos)
+}
+
+// AllDst 分表按照全局顺序编号，每个库依次负责TableCount个分表
+func (d *hashDataFactory) AllDst() []Dst {
+	res := make([]Dst, 0, d.totalTableCount)
+	currentPos := 0
+	for _, ds := range d.dbs {
+		for i := 0; i < ds.TableCount; i++ {
+			res = append(res, Dst{
+				DB:    ds.DB,
+				Table: fmt.Sprintf("%s%d", d.TablePrefix, currentPos+i),
+			})
+		}
+		currentPos += ds.TableCount
+	}
+
+	return res
+}
+
+// Period 时间分表的周期
+type Period string
+
+const (
+	// PeriodMonth 按照自然月分表，表名后缀为200601
+	PeriodMonth Period = "month"
+	// PeriodWeek 按照自然周分表，每周从周一开始，表名后缀为周一的日期20060102
+	PeriodWeek Period = "week"
+)
+
+// TimeFactory 基于时间的分片算法，除了按照时间路由外，还支持按照时间区间查询分表和提前创建分表
+type TimeFactory interface {
+	Factory
+	// Tables 获取时间区间[from, to]覆盖的所有分表，用于范围查询，超出分库配置的周期会被忽略
+	Tables(from time.Time, to time.Time) []Dst
+	// Migrate 通过GORM迁移创建now所在周期及之后ahead个周期的分表，已经存在的分表会同步表结构
+	Migrate(ctx context.Context, model any, now time.Time, ahead int) error
 }
 
 // TimeDataSource 时间数据源的配置
 type TimeDataSource struct {
-	// 当前库的表数量对应的是负责处理几个月份的分表
+	// 当前库的表数量对应的是负责处理几个周期的分表
 	DS DataSource
-	// 当前库中表的起始位置，标识是从第几个月开始
+	// 当前库中表的起始位置，标识是从基准时间所在周期之后的第几个周期开始
 	StartOffset int
 }
 
+type TimeOption func(t *timeDataFactory)
+
+// WithPeriod 设置分表的周期，默认按照自然月分表
+func WithPeriod(period Period) TimeOption {
+	return func(t *timeDataFactory) {
+		t.period = period
+	}
+}
+
 // timeDataFactory 基于时间来实现的分片算法实现
 type timeDataFactory struct {
 	// 数据库表信息
@@ -104,29 +178,138 @@ type timeDataFactory struct {
 	TablePrefix string
 	// 分库分表的基准时间，也就是开始时间，从哪个时间点来计算分库分表
 	baseTime time.Time
+	// 分表周期
+	period Period
 }
 
-func NewTimeDataSource(dbs []TimeDataSource, tablePrefix string, baseTime time.Time) Factory {
-	return &timeDataFactory{
+func NewTimeDataSource(dbs []TimeDataSource, tablePrefix string, baseTime time.Time, opts ...TimeOption) TimeFactory {
+	t := &timeDataFactory{
 		dbs:         dbs,
 		TablePrefix: tablePrefix,
 		baseTime:    baseTime,
+		period:      PeriodMonth,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	// 基准时间对齐到所在周期的开始
+	t.baseTime = t.periodStart(baseTime)
+	return t
+}
+
+func (t *timeDataFactory) GetDB(shardingKey ShardingKey) (Dst, error) {
+	st, ok := shardingKey.(TimeKey)
+	if !ok {
+		return Dst{}, unsupported(shardingKey, "时间")
 	}
+
+	idx := t.periodIndex(time.Time(st))
+	dst, ok := t.dst(idx)
+	if !ok {
+		return Dst{}, fmt.Errorf("%w: 时间%s所在的分表%s没有对应的分库", generator.ErrShardingFailed, st, t.tableName(idx))
+	}
+
+	return dst, nil
+}
+
+// AllDst 按照GetDB的规则枚举每个库负责的周期内的所有分表
+func (t *timeDataFactory) AllDst() []Dst {
+	var res []Dst
+	for _, d := range t.dbs {
+		for i := 0; i < d.DS.TableCount; i++ {
+			res = append(res, Dst{
+				DB:    d.DS.DB,
+				Table: t.tableName(d.StartOffset + i),
+			})
+		}
+	}
+
+	return res
+}
+
+func (t *timeDataFactory) Tables(from time.Time, to time.Time) []Dst {
+	var res []Dst
+	for idx := t.periodIndex(from); idx <= t.periodIndex(to); idx++ {
+		if dst, ok := t.dst(idx); ok {
+			res = append(res, dst)
+		}
+	}
+
+	return res
+}
+
+func (t *timeDataFactory) Migrate(ctx context.Context, model any, now time.Time, ahead int) error {
+	start := t.periodIndex(now)
+	for idx := start; idx <= start+ahead; idx++ {
+		dst, ok := t.dst(idx)
+		if !ok {
+			// 分库配置没有覆盖的周期无法创建，需要提前扩容
+			return fmt.Errorf("%w: 第%d个周期的分表%s没有对应的分库", generator.ErrShardingFailed, idx, t.tableName(idx))
+		}
+
+		err := dst.DB.WithContext(ctx).Table(dst.Table).AutoMigrate(model)
+		if err != nil {
+			return fmt.Errorf("创建分表%s失败: %w", dst.Table, err)
+		}
+	}
+
+	return nil
 }
 
-func (t *timeDataFactory) GetDB(shardingKey any) (Dst, error) {
-	st := shardingKey.(time.Time)
-	months := int(st.Sub(t.baseTime).Hours() / 24 / 30)
+// dst 获取第idx个周期所在的分表
+func (t *timeDataFactory) dst(idx int) (Dst, bool) {
 	for _, d := range t.dbs {
-		start := d.StartOffset
-		end := start + d.DS.TableCount
-		if months > start && months < end {
+		if idx >= d.StartOffset && idx < d.StartOffset+d.DS.TableCount {
 			return Dst{
 				DB:    d.DS.DB,
-				Table: fmt.Sprintf("%s%s", t.TablePrefix, st.Format("20060102")),
-			}, nil
+				Table: t.tableName(idx),
+			}, true
 		}
 	}
 
-	return Dst{}, generator.ErrShardingFailed
+	return Dst{}, false
+}
+
+// periodIndex 计算st所在的周期相对基准时间所在周期的序号，早于基准时间时为负数。
+// 按照基准时间的时区计算日历日期，避免夏令时导致的天数误差
+func (t *timeDataFactory) periodIndex(st time.Time) int {
+	st = st.In(t.baseTime.Location())
+	switch t.period {
+	case PeriodWeek:
+		return days(t.baseTime, t.periodStart(st)) / 7
+	default:
+		return (st.Year()-t.baseTime.Year())*12 + int(st.Month()-t.baseTime.Month())
+	}
+}
+
+// periodStart 计算st所在周期的开始时间
+func (t *timeDataFactory) periodStart(st time.Time) time.Time {
+	y, m, d := st.Date()
+	switch t.period {
+	case PeriodWeek:
+		// 周一为一周的开始
+		offset := (int(st.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, st.Location())
+	default:
+		return time.Date(y, m, 1, 0, 0, 0, 0, st.Location())
+	}
+}
+
+// tableName 第idx个周期的分表名
+func (t *timeDataFactory) tableName(idx int) string {
+	switch t.period {
+	case PeriodWeek:
+		return t.TablePrefix + t.baseTime.AddDate(0, 0, idx*7).Format("20060102")
+	default:
+		return t.TablePrefix + t.baseTime.AddDate(0, idx, 0).Format("200601")
+	}
+}
+
+// days 计算两个日历日期之间相差的天数
+func days(from time.Time, to time.Time) int {
+	fy, fm, fd := from.Date()
+	ty, tm, td := to.Date()
+	return int(time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
 }
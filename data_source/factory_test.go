@@ -45,7 +45,7 @@ func TestNewDataFactory(t *testing.T) {
 
 	f := NewHashDataFactory(dbs, 20, "order_")
 	for i := 0; i < 14; i++ {
-		dst, err := f.GetDB(i)
+		dst, err := f.GetDB(Int64Key(i))
 		assert.Nil(t, err)
 		t.Logf("dst message: %v", dst)
 	}
@@ -71,7 +71,7 @@ func TestNewTimeDataSource(t *testing.T) {
 		{
 			DS: DataSource{
 				DB:         db2,
-				TableCount: 6,
+				TableCount: 12,
 			},
 			StartOffset: 10,
 		},
@@ -82,7 +82,7 @@ func TestNewTimeDataSource(t *testing.T) {
 	for i := 0; i < 25; i++ {
 		rd := rand.Intn(10)
 		shardingKey := time.Now().AddDate(0, rd, 0)
-		dst, er := f.GetDB(shardingKey)
+		dst, er := f.GetDB(TimeKey(shardingKey))
 		assert.Nil(t, er)
 		t.Logf("dst message: %v", dst)
 	}
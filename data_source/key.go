@@ -0,0 +1,101 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_source
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/TimeWtr/generator"
+)
+
+// KeyKind 分片键的类型
+type KeyKind string
+
+const (
+	KeyKindString KeyKind = "string"
+	KeyKindInt64  KeyKind = "int64"
+	KeyKindTime   KeyKind = "time"
+	KeyKindBiz    KeyKind = "biz"
+)
+
+// ShardingKey 分片键，不同的分片算法支持不同类型的分片键，类型不支持时返回包装了ErrShardingFailed的错误
+type ShardingKey interface {
+	// Kind 分片键的类型
+	Kind() KeyKind
+	// String 分片键的字符串表示，用于一致性哈希和错误信息
+	String() string
+}
+
+// StringKey 字符串分片键，按照哈希取模时使用字符串的哈希值
+type StringKey string
+
+func (k StringKey) Kind() KeyKind {
+	return KeyKindString
+}
+
+func (k StringKey) String() string {
+	return string(k)
+}
+
+// Int64Key 整数分片键，按照哈希取模时直接使用整数值
+type Int64Key int64
+
+func (k Int64Key) Kind() KeyKind {
+	return KeyKindInt64
+}
+
+func (k Int64Key) String() string {
+	return strconv.FormatInt(int64(k), 10)
+}
+
+// TimeKey 时间分片键，只支持按照时间分片
+type TimeKey time.Time
+
+func (k TimeKey) Kind() KeyKind {
+	return KeyKindTime
+}
+
+func (k TimeKey) String() string {
+	return time.Time(k).Format(time.RFC3339)
+}
+
+// BizKey 组合分片键，先按照业务路由到业务的分库分表，再使用Key在业务的分库分表中分片
+type BizKey struct {
+	Biz string
+	Key ShardingKey
+}
+
+func (k BizKey) Kind() KeyKind {
+	return KeyKindBiz
+}
+
+func (k BizKey) String() string {
+	if k.Key == nil {
+		return k.Biz + "/"
+	}
+
+	return k.Biz + "/" + k.Key.String()
+}
+
+// unsupported 分片键类型不被分片算法支持的错误
+func unsupported(key ShardingKey, algorithm string) error {
+	if key == nil {
+		return fmt.Errorf("%w: %s分片的分片键为空", generator.ErrShardingFailed, algorithm)
+	}
+
+	return fmt.Errorf("%w: %s分片不支持%s类型的分片键%s", generator.ErrShardingFailed, algorithm, key.Kind(), key)
+}
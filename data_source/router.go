@@ -0,0 +1,95 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_source
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/TimeWtr/generator"
+)
+
+// bizRouter 组合分片，先按照业务路由到业务独占的分库分表，再由业务的分片算法计算分片。
+// 没有独占分库分表的业务和不包含业务的分片键使用默认的分库分表
+type bizRouter struct {
+	// 业务独占的分库分表
+	routes map[string]Factory
+	// 默认的分库分表，为nil时只能路由到业务独占的分库分表
+	fallback Factory
+}
+
+func NewBizRouter(routes map[string]Factory, fallback Factory) Factory {
+	return &bizRouter{
+		routes:   routes,
+		fallback: fallback,
+	}
+}
+
+func (b *bizRouter) GetDB(shardingKey ShardingKey) (Dst, error) {
+	key, ok := shardingKey.(BizKey)
+	if !ok {
+		if b.fallback == nil {
+			return Dst{}, unsupported(shardingKey, "业务")
+		}
+		return b.fallback.GetDB(shardingKey)
+	}
+
+	f, ok := b.routes[key.Biz]
+	if !ok {
+		f = b.fallback
+	}
+
+	if f == nil {
+		return Dst{}, fmt.Errorf("%w: 业务%s没有对应的分库分表", generator.ErrShardingFailed, key.Biz)
+	}
+
+	dst, err := f.GetDB(key.Key)
+	if err != nil {
+		return Dst{}, fmt.Errorf("业务%s分片失败: %w", key.Biz, err)
+	}
+
+	return dst, nil
+}
+
+// AllDst 依次返回默认的分库分表和按照业务名称排序的业务独占分库分表，多个业务共享的分表只返回一次
+func (b *bizRouter) AllDst() []Dst {
+	var factories []Factory
+	if b.fallback != nil {
+		factories = append(factories, b.fallback)
+	}
+
+	bizs := make([]string, 0, len(b.routes))
+	for biz := range b.routes {
+		bizs = append(bizs, biz)
+	}
+	slices.Sort(bizs)
+	for _, biz := range bizs {
+		factories = append(factories, b.routes[biz])
+	}
+
+	var res []Dst
+	seen := make(map[Dst]struct{})
+	for _, f := range factories {
+		for _, dst := range f.AllDst() {
+			if _, ok := seen[dst]; ok {
+				continue
+			}
+			seen[dst] = struct{}{}
+			res = append(res, dst)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_source
+
+import (
+	"testing"
+
+	"github.com/TimeWtr/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBizRouter(t *testing.T) {
+	shared, vip := &gorm.DB{}, &gorm.DB{}
+	f := NewBizRouter(map[string]Factory{
+		"vip": NewConsistentHashFactory([]DataSource{{DB: vip, TableCount: 2}}, "vip_short_code_", 0),
+	}, NewHashDataFactory([]DataSource{{DB: shared, TableCount: 4}}, 4, "short_code_"))
+
+	testCases := []struct {
+		name string
+		key  ShardingKey
+		db   *gorm.DB
+	}{
+		{name: "业务独占分库", key: BizKey{Biz: "vip", Key: StringKey("abc")}, db: vip},
+		{name: "业务使用默认分库", key: BizKey{Biz: "marketing", Key: Int64Key(7)}, db: shared},
+		{name: "不包含业务", key: Int64Key(7), db: shared},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, err := f.GetDB(tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, tc.db, dst.DB)
+		})
+	}
+
+	// 一致性哈希不支持时间分片键
+	_, err := f.GetDB(BizKey{Biz: "vip", Key: TimeKey{}})
+	assert.ErrorIs(t, err, generator.ErrShardingFailed)
+
+	_, err = NewBizRouter(nil, nil).GetDB(BizKey{Biz: "vip", Key: StringKey("abc")})
+	assert.ErrorIs(t, err, generator.ErrShardingFailed)
+
+	assert.Len(t, f.AllDst(), 6)
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_source
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestTimeDataFactory_Month(t *testing.T) {
+	db1, db2 := &gorm.DB{}, &gorm.DB{}
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	f := NewTimeDataSource([]TimeDataSource{
+		{DS: DataSource{DB: db1, TableCount: 2}, StartOffset: 0},
+		{DS: DataSource{DB: db2, TableCount: 12}, StartOffset: 2},
+	}, "short_code_", base)
+
+	testCases := []struct {
+		name  string
+		key   time.Time
+		db    *gorm.DB
+		table string
+	}{
+		{name: "基准月第一天", key: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), db: db1, table: "short_code_202501"},
+		{name: "二月最后一刻", key: time.Date(2025, 2, 28, 23, 59, 59, 0, time.UTC), db: db1, table: "short_code_202502"},
+		{name: "第二个库的第一个月", key: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), db: db2, table: "short_code_202503"},
+		{name: "跨年", key: time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), db: db2, table: "short_code_202601"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, err := f.GetDB(TimeKey(tc.key))
+			require.NoError(t, err)
+			assert.Equal(t, tc.db, dst.DB)
+			assert.Equal(t, tc.table, dst.Table)
+		})
+	}
+
+	_, err := f.GetDB(TimeKey(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)))
+	assert.Error(t, err)
+	_, err = f.GetDB(TimeKey(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Error(t, err)
+	_, err = f.GetDB(Int64Key(1))
+	assert.Error(t, err)
+
+	assert.Len(t, f.AllDst(), 14)
+	tables := f.Tables(time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC))
+	require.Len(t, tables, 3)
+	assert.Equal(t, "short_code_202502", tables[0].Table)
+	assert.Equal(t, "short_code_202504", tables[2].Table)
+}
+
+func TestTimeDataFactory_Week(t *testing.T) {
+	// 2025-01-01是周三，所在周从2024-12-30开始
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewTimeDataSource([]TimeDataSource{
+		{DS: DataSource{DB: &gorm.DB{}, TableCount: 10}},
+	}, "short_code_", base, WithPeriod(PeriodWeek))
+
+	dst, err := f.GetDB(TimeKey(time.Date(2025, 1, 5, 23, 0, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	assert.Equal(t, "short_code_20241230", dst.Table)
+
+	dst, err = f.GetDB(TimeKey(time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	assert.Equal(t, "short_code_20250106", dst.Table)
+
+	tables := f.Tables(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+	assert.Len(t, tables, 4)
+}
+
+func TestTimeDataFactory_Migrate(t *testing.T) {
+	type record struct {
+		ID   int64
+		Name string
+	}
+	// 新版本的模型增加了字段
+	type recordV2 struct {
+		ID     int64
+		Name   string
+		Remark string
+	}
+
+	open := func(name string) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(t.TempDir()+"/"+name+".db"),
+			&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		require.NoError(t, err)
+		return db
+	}
+	db1, db2 := open("db1"), open("db2")
+	f := NewTimeDataSource([]TimeDataSource{
+		{DS: DataSource{DB: db1, TableCount: 2}, StartOffset: 0},
+		{DS: DataSource{DB: db2, TableCount: 2}, StartOffset: 2},
+	}, "record_", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	// 创建当前周期及之后的分表，跨越两个库
+	require.NoError(t, f.Migrate(ctx, &record{}, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), 2))
+	assert.True(t, db1.Migrator().HasTable("record_202501"))
+	assert.True(t, db1.Migrator().HasTable("record_202502"))
+	assert.True(t, db2.Migrator().HasTable("record_202503"))
+	assert.False(t, db2.Migrator().HasTable("record_202504"))
+
+	// 已经存在的分表同步表结构
+	require.NoError(t, f.Migrate(ctx, &recordV2{}, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), 2))
+	assert.True(t, db1.Table("record_202502").Migrator().HasColumn(&recordV2{}, "remark"))
+	assert.True(t, db2.Table("record_202504").Migrator().HasColumn(&recordV2{}, "remark"))
+	assert.False(t, db1.Table("record_202501").Migrator().HasColumn(&recordV2{}, "remark"))
+
+	// 分库配置没有覆盖的周期
+	err := f.Migrate(ctx, &recordV2{}, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), 2)
+	assert.ErrorIs(t, err, generator.ErrShardingFailed)
+}
@@ -0,0 +1,48 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import "time"
+
+// NeverExpire 过期时间为0表示短链永不过期
+const NeverExpire int64 = 0
+
+// ExpiryKind 短链的过期方式
+type ExpiryKind uint8
+
+const (
+	// ExpiryDefault 未指定过期方式，使用业务的默认有效期
+	ExpiryDefault ExpiryKind = iota
+	// ExpiryAt 指定绝对过期时间
+	ExpiryAt
+	// ExpiryAfter 指定有效期
+	ExpiryAfter
+	// ExpiryNever 永不过期
+	ExpiryNever
+)
+
+// Expiry 请求指定的过期方式
+type Expiry struct {
+	Kind ExpiryKind
+	// 绝对过期时间，毫秒时间戳，Kind为ExpiryAt时有效
+	At int64
+	// 有效期，Kind为ExpiryAfter时有效
+	After time.Duration
+}
+
+// Expired 判断过期时间为expireAt的短链在now(毫秒时间戳)时是否已经过期
+func Expired(expireAt int64, now int64) bool {
+	return expireAt != NeverExpire && expireAt <= now
+}
@@ -14,6 +14,11 @@
 
 package domain
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
 type URLResponse struct {
 	ID        int64
 	OriginURL string
@@ -23,11 +28,27 @@ type URLResponse struct {
 
 type URLData struct {
 	ID        int64
+	Biz       string
 	OriginURL string
 	ShortCode string
 	ExpireAt  int64
 	Comment   string
 	Creator   string
+	// 是否是业务方指定的自定义短码，自定义短码不能回收到短码池
+	Custom    bool
 	CreatedAt int64
 	UpdatedAt int64
 }
+
+// BatchURLResult 批量生成时单条URL的生成结果
+type BatchURLResult struct {
+	URLResponse
+	// 生成失败的原因，成功时为nil
+	Err error
+}
+
+// HashURL 计算原始URL的哈希，用于按照原始URL查询短链，原始URL过长不适合直接建立索引
+func HashURL(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
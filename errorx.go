@@ -17,5 +17,13 @@ package generator
 import "github.com/pkg/errors"
 
 var (
-	ErrShardingFailed = errors.New("分片计算错误")
+	ErrShardingFailed      = errors.New("分片计算错误")
+	ErrURLNotFound         = errors.New("短链不存在")
+	ErrURLExpired          = errors.New("短链已过期")
+	ErrURLForbidden        = errors.New("无权操作该短链")
+	ErrCustomCodeInvalid   = errors.New("自定义短码不合法")
+	ErrCustomCodeTaken     = errors.New("自定义短码已被占用")
+	ErrRequestInProgress   = errors.New("相同幂等键的请求正在处理中")
+	ErrIdempotencyConflict = errors.New("幂等键已被其他请求使用")
+	ErrExpirationInvalid   = errors.New("过期时间不合法")
 )
@@ -26,3 +26,31 @@ type Event struct {
 }
 
 type HandleFunc func(ctx context.Context, evt *Event) error
+
+type URLEventType string
+
+const (
+	// URLEventUpdate 短链的原始URL、备注或过期时间被修改
+	URLEventUpdate URLEventType = "update"
+	// URLEventDelete 短链被删除
+	URLEventDelete URLEventType = "delete"
+	// URLEventExpire 短链过期后被清理
+	URLEventExpire URLEventType = "expire"
+)
+
+// URLEvent 短链变更事件，通过本地消息表投递到generator.Topic，
+// 跳转服务消费后刷新或清理对应短码的缓存映射
+type URLEvent struct {
+	// 事件类型
+	Type URLEventType `json:"type"`
+	// 短链ID
+	ID int64 `json:"id"`
+	// 所属业务
+	Biz string `json:"biz,omitempty"`
+	// 短码
+	ShortCode string `json:"short_code"`
+	// 原始的URL
+	OriginalURL string `json:"original_url,omitempty"`
+	// 过期时间
+	ExpireAt int64 `json:"expire_at,omitempty"`
+}
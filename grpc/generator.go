@@ -22,6 +22,8 @@ import (
 	"github.com/TimeWtr/generator/domain"
 	"github.com/TimeWtr/generator/service"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type GeneratorServiceServer struct {
@@ -29,59 +31,298 @@ type GeneratorServiceServer struct {
 	srv service.URLServiceInter
 }
 
+func NewGeneratorServiceServer(srv service.URLServiceInter) *GeneratorServiceServer {
+	return &GeneratorServiceServer{srv: srv}
+}
+
 func (g *GeneratorServiceServer) GenerateURL(ctx context.Context, req *intrv1.URLRequest) (*intrv1.URLResponse, error) {
 	if req.GetBiz() == "" {
 		return nil, errors.New("biz is required")
 	}
 
-	if req.GetMeta().GetOriginalUrl() == "" {
-		return nil, errors.New("origin url is required")
+	if req.GetCreator() == "" {
+		return nil, errors.New("creator is required")
+	}
+
+	if err := g.validateMeta(req.GetMeta()); err != nil {
+		return nil, err
+	}
+
+	res, err := g.srv.GenerateURL(ctx, req)
+	if err != nil {
+		return nil, g.toStatus(err)
+	}
+
+	return g.toDTO(res), nil
+}
+
+// BatchGenerateURL 批量生成短链，元数据校验失败的URL直接记录为失败，不会提交到服务层，
+// 返回的results与请求中meta的顺序一致，resp中只包含生成成功的短链
+func (g *GeneratorServiceServer) BatchGenerateURL(ctx context.Context, req *intrv1.BatchURLRequest) (*intrv1.BatchURLResponse, error) {
+	if req.GetBiz() == "" {
+		return nil, errors.New("biz is required")
 	}
 
 	if req.GetCreator() == "" {
 		return nil, errors.New("creator is required")
 	}
 
-	expiration := req.GetMeta().GetExpiration()
-	switch expiration {
-	case generator.SevenDays, generator.FifteenDays, generator.ThirtyDays:
+	metas := req.GetMeta()
+	if len(metas) == 0 {
+		return nil, errors.New("meta is required")
+	}
+
+	results := make([]*intrv1.BatchURLResult, len(metas))
+	// 通过校验的meta在原请求中的下标
+	indexes := make([]int, 0, len(metas))
+	valid := make([]*intrv1.Metadata, 0, len(metas))
+	for i, meta := range metas {
+		if err := g.validateMeta(meta); err != nil {
+			results[i] = &intrv1.BatchURLResult{
+				Index:      int64(i),
+				StatusCode: 400,
+				Message:    err.Error(),
+			}
+			continue
+		}
+
+		indexes = append(indexes, i)
+		valid = append(valid, meta)
+	}
+
+	if len(valid) > 0 {
+		res, err := g.srv.BatchGenerateURL(ctx, &intrv1.BatchURLRequest{
+			Biz:     req.GetBiz(),
+			Meta:    valid,
+			Creator: req.GetCreator(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i, r := range res {
+			idx := indexes[i]
+			if r.Err != nil {
+				statusCode := int64(500)
+				switch {
+				case errors.Is(r.Err, generator.ErrCustomCodeInvalid),
+					errors.Is(r.Err, generator.ErrExpirationInvalid):
+					statusCode = 400
+				case errors.Is(r.Err, generator.ErrCustomCodeTaken),
+					errors.Is(r.Err, generator.ErrRequestInProgress),
+					errors.Is(r.Err, generator.ErrIdempotencyConflict):
+					statusCode = 409
+				}
+
+				results[idx] = &intrv1.BatchURLResult{
+					Index:      int64(idx),
+					StatusCode: statusCode,
+					Message:    r.Err.Error(),
+				}
+				continue
+			}
+
+			results[idx] = &intrv1.BatchURLResult{
+				Index:      int64(idx),
+				Content:    g.toContent(r.URLResponse),
+				StatusCode: 200,
+				Message:    "generate success",
+			}
+		}
+	}
+
+	resp := &intrv1.BatchURLResponse{
+		Resp:    make([]*intrv1.URLResponseContent, 0, len(results)),
+		Results: results,
+	}
+	for _, r := range results {
+		if r.GetStatusCode() == 200 {
+			resp.Resp = append(resp.Resp, r.GetContent())
+		}
+	}
+
+	switch len(resp.Resp) {
+	case len(results):
+		resp.StatusCode, resp.Message = 200, "generate success"
+	case 0:
+		resp.StatusCode, resp.Message = 500, "generate failed"
 	default:
+		resp.StatusCode, resp.Message = 206, "generate partially success"
+	}
+
+	return resp, nil
+}
+
+// UpdateURL 修改短链的原始URL、备注或过期时间，至少需要指定其中一项
+func (g *GeneratorServiceServer) UpdateURL(ctx context.Context, req *intrv1.UpdateURLRequest) (*intrv1.URLResponse, error) {
+	if req.GetBiz() == "" {
+		return nil, errors.New("biz is required")
+	}
+
+	if req.GetId() <= 0 {
+		return nil, errors.New("id is invalid")
+	}
+
+	meta := req.GetMeta()
+	if meta.GetOriginalUrl() == "" && meta.GetComment() == "" &&
+		meta.GetExpiration() == 0 && meta.GetExpiry() == nil {
+		return nil, errors.New("nothing to update")
+	}
+
+	if meta.GetExpiration() < 0 {
 		return nil, errors.New("expiration is invalid")
 	}
 
-	res, err := g.srv.GenerateURL(ctx, req)
+	res, err := g.srv.UpdateURL(ctx, req)
 	if err != nil {
+		switch {
+		case errors.Is(err, generator.ErrURLNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, generator.ErrURLForbidden):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case errors.Is(err, generator.ErrExpirationInvalid):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		return nil, err
 	}
 
-	return g.toDTO(res), nil
+	resp := g.toDTO(res)
+	resp.Message = "update success"
+	return resp, nil
 }
 
-func (g *GeneratorServiceServer) BatchGenerateURL(ctx context.Context, req *intrv1.BatchURLRequest) (*intrv1.BatchURLResponse, error) {
-	//TODO implement me
-	panic("implement me")
+func (g *GeneratorServiceServer) DeleteURL(ctx context.Context, req *intrv1.DelRequest) (*intrv1.DelResponse, error) {
+	if req.GetBiz() == "" {
+		return nil, errors.New("biz is required")
+	}
+
+	if req.GetId() <= 0 {
+		return nil, errors.New("id is invalid")
+	}
+
+	err := g.srv.DeleteURL(ctx, req)
+	switch {
+	case err == nil:
+	case errors.Is(err, generator.ErrURLNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, generator.ErrURLForbidden):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return nil, err
+	}
+
+	return &intrv1.DelResponse{
+		Code:    200,
+		Message: "delete success",
+	}, nil
 }
 
-func (g *GeneratorServiceServer) UpdateURL(ctx context.Context, req *intrv1.URLRequest) (*intrv1.URLResponse, error) {
-	//TODO implement me
-	panic("implement me")
+// LookupByURL 查询所有分片中指向原始URL的短链，供风控和客服排查使用
+func (g *GeneratorServiceServer) LookupByURL(ctx context.Context, req *intrv1.LookupByURLRequest) (*intrv1.LookupByURLResponse, error) {
+	if req.GetOriginalUrl() == "" {
+		return nil, errors.New("origin url is required")
+	}
+
+	res, err := g.srv.LookupByURL(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]*intrv1.ShortCodeDetail, 0, len(res))
+	for _, r := range res {
+		details = append(details, &intrv1.ShortCodeDetail{
+			Id:          r.ID,
+			Biz:         r.Biz,
+			OriginalUrl: r.OriginURL,
+			ShortCode:   r.ShortCode,
+			ExpireAt:    r.ExpireAt,
+			Creator:     r.Creator,
+			Comment:     r.Comment,
+			CreateTime:  r.CreatedAt,
+		})
+	}
+
+	return &intrv1.LookupByURLResponse{
+		StatusCode: 200,
+		Message:    "lookup success",
+		Codes:      details,
+	}, nil
 }
 
-func (g *GeneratorServiceServer) DeleteURL(ctx context.Context, req *intrv1.DelRequest) (*intrv1.DelResponse, error) {
-	//TODO implement me
-	panic("implement me")
+// ResolveShortCode 解析短码对应的原始URL，过期的短码返回FailedPrecondition，与不存在的短码区分开
+func (g *GeneratorServiceServer) ResolveShortCode(ctx context.Context, req *intrv1.ResolveRequest) (*intrv1.ResolveResponse, error) {
+	if req.GetShortCode() == "" {
+		return nil, errors.New("short code is required")
+	}
+
+	res, err := g.srv.ResolveShortCode(ctx, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, generator.ErrURLNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, generator.ErrURLExpired):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return nil, err
+		}
+	}
+
+	return &intrv1.ResolveResponse{
+		Resp: &intrv1.URLResponseContent{
+			OriginalUrl: res.OriginURL,
+			ShortCode:   res.ShortCode,
+			ExpireAt:    res.ExpireAt,
+		},
+		StatusCode: 200,
+		Message:    "resolve success",
+	}, nil
 }
 
 func (g *GeneratorServiceServer) mustEmbedUnimplementedGeneratorServer() {}
 
+// toStatus 将生成短码时的业务错误转换为对应的gRPC错误码
+func (g *GeneratorServiceServer) toStatus(err error) error {
+	switch {
+	case errors.Is(err, generator.ErrCustomCodeInvalid),
+		errors.Is(err, generator.ErrExpirationInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, generator.ErrCustomCodeTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, generator.ErrIdempotencyConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, generator.ErrRequestInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	default:
+		return err
+	}
+}
+
+// validateMeta 校验单条短链的元数据
+func (g *GeneratorServiceServer) validateMeta(meta *intrv1.Metadata) error {
+	if meta.GetOriginalUrl() == "" {
+		return errors.New("origin url is required")
+	}
+
+	// 过期时间是否超过业务的限制由服务层按照过期策略校验
+	if meta.GetExpiration() < 0 {
+		return errors.New("expiration is invalid")
+	}
+
+	return nil
+}
+
 func (g *GeneratorServiceServer) toDTO(url domain.URLResponse) *intrv1.URLResponse {
 	return &intrv1.URLResponse{
 		StatusCode: 200,
 		Message:    "generate success",
-		Resp: &intrv1.URLResponseContent{
-			OriginalUrl: url.OriginURL,
-			ShortCode:   url.ShortCode,
-			ExpireAt:    url.ExpireAt,
-		},
+		Resp:       g.toContent(url),
+	}
+}
+
+func (g *GeneratorServiceServer) toContent(url domain.URLResponse) *intrv1.URLResponseContent {
+	return &intrv1.URLResponseContent{
+		OriginalUrl: url.OriginURL,
+		ShortCode:   url.ShortCode,
+		ExpireAt:    url.ExpireAt,
 	}
 }
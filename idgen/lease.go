@@ -0,0 +1,121 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+//go:embed scripts/renew.lua
+var renewScript string
+
+//go:embed scripts/release.lua
+var releaseScript string
+
+const (
+	// DefaultLeaseTTL 默认的租约有效期
+	DefaultLeaseTTL = 30 * time.Second
+	// WorkerKeyPrefix 机器ID租约的key前缀，value是持有者
+	WorkerKeyPrefix = "SnowflakeWorker:"
+	// WorkerLastTimeKeyPrefix 机器ID最后一次生成ID的时间戳，新的持有者从这个时间之后开始生成
+	WorkerLastTimeKeyPrefix = "SnowflakeWorkerLastTime:"
+)
+
+var (
+	ErrNoWorkerID = errors.New("没有可用的机器ID")
+	ErrLeaseLost  = errors.New("机器ID租约已经失效")
+)
+
+// WorkerLease 通过Redis SET NX租用机器ID，持有者需要在有效期内续约，
+// 续约失败或者租约被其他节点占用时必须立即停止使用该机器ID
+type WorkerLease struct {
+	client redis.Cmdable
+	// 持有者标识，每个实例必须唯一，比如Pod名称
+	owner string
+	// 租约有效期
+	ttl time.Duration
+}
+
+func NewWorkerLease(client redis.Cmdable, owner string, ttl time.Duration) *WorkerLease {
+	return &WorkerLease{
+		client: client,
+		owner:  owner,
+		ttl:    ttl,
+	}
+}
+
+func workerKey(workerID int64) string {
+	return fmt.Sprintf("%s%d", WorkerKeyPrefix, workerID)
+}
+
+func workerLastTimeKey(workerID int64) string {
+	return fmt.Sprintf("%s%d", WorkerLastTimeKeyPrefix, workerID)
+}
+
+// Acquire 从随机位置开始依次尝试租用机器ID，返回租到的机器ID和原持有者最后一次生成ID的时间戳
+func (l *WorkerLease) Acquire(ctx context.Context) (workerID int64, lastTime int64, err error) {
+	offset := rand.Int63n(MaxWorkerID + 1)
+	for i := int64(0); i <= MaxWorkerID; i++ {
+		id := (offset + i) % (MaxWorkerID + 1)
+		ok, er := l.client.SetNX(ctx, workerKey(id), l.owner, l.ttl).Result()
+		if er != nil {
+			return 0, 0, er
+		}
+
+		if !ok {
+			continue
+		}
+
+		lastTime, er = l.client.Get(ctx, workerLastTimeKey(id)).Int64()
+		if er != nil && !errors.Is(er, redis.Nil) {
+			_ = l.Release(ctx, id, 0)
+			return 0, 0, er
+		}
+
+		return id, lastTime, nil
+	}
+
+	return 0, 0, ErrNoWorkerID
+}
+
+// Renew 续约并记录最后一次生成ID的时间戳，租约已经不属于当前实例时返回ErrLeaseLost
+func (l *WorkerLease) Renew(ctx context.Context, workerID int64, lastTime int64) error {
+	ok, err := l.client.Eval(ctx, renewScript,
+		[]string{workerKey(workerID), workerLastTimeKey(workerID)},
+		l.owner, l.ttl.Milliseconds(), lastTime).Bool()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// Release 记录最后一次生成ID的时间戳并释放租约
+func (l *WorkerLease) Release(ctx context.Context, workerID int64, lastTime int64) error {
+	return l.client.Eval(ctx, releaseScript,
+		[]string{workerKey(workerID), workerLastTimeKey(workerID)},
+		l.owner, lastTime).Err()
+}
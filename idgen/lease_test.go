@@ -0,0 +1,229 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// occupyExcept 其他节点占用除workerID之外的所有机器ID
+func occupyExcept(t *testing.T, mr *miniredis.Miniredis, workerID int64) {
+	for id := int64(0); id <= MaxWorkerID; id++ {
+		if id != workerID {
+			require.NoError(t, mr.Set(workerKey(id), "other"))
+		}
+	}
+}
+
+func TestWorkerLease_AcquireRenewRelease(t *testing.T) {
+	mr, client := testutil.NewRedis(t)
+	ctx := context.Background()
+	a := NewWorkerLease(client, "a", time.Minute)
+
+	id, lastTime, err := a.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, lastTime)
+	owner, err := mr.Get(workerKey(id))
+	require.NoError(t, err)
+	assert.Equal(t, "a", owner)
+	assert.Equal(t, time.Minute, mr.TTL(workerKey(id)))
+
+	// 续约刷新有效期，时间戳只增不减
+	mr.FastForward(30 * time.Second)
+	require.NoError(t, a.Renew(ctx, id, 200))
+	assert.Equal(t, time.Minute, mr.TTL(workerKey(id)))
+	require.NoError(t, a.Renew(ctx, id, 100))
+	saved, err := mr.Get(workerLastTimeKey(id))
+	require.NoError(t, err)
+	assert.Equal(t, "200", saved)
+
+	// 释放后其他节点可以立即租用，并从释放时的时间戳之后开始生成
+	require.NoError(t, a.Release(ctx, id, 300))
+	assert.False(t, mr.Exists(workerKey(id)))
+
+	occupyExcept(t, mr, id)
+	b := NewWorkerLease(client, "b", time.Minute)
+	bid, lastTime, err := b.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, id, bid)
+	assert.Equal(t, int64(300), lastTime)
+
+	// 所有机器ID都被占用
+	_, _, err = NewWorkerLease(client, "c", time.Minute).Acquire(ctx)
+	assert.ErrorIs(t, err, ErrNoWorkerID)
+}
+
+func TestWorkerLease_Takeover(t *testing.T) {
+	mr, client := testutil.NewRedis(t)
+	ctx := context.Background()
+	a := NewWorkerLease(client, "a", time.Minute)
+
+	id, _, err := a.Acquire(ctx)
+	require.NoError(t, err)
+	require.NoError(t, a.Renew(ctx, id, 100))
+
+	// 租约过期后被其他节点接管
+	mr.FastForward(time.Minute)
+	occupyExcept(t, mr, id)
+	b := NewWorkerLease(client, "b", time.Minute)
+	bid, lastTime, err := b.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, id, bid)
+	assert.Equal(t, int64(100), lastTime)
+
+	// 原持有者不能续约，也不能释放新持有者的租约
+	assert.ErrorIs(t, a.Renew(ctx, id, 200), ErrLeaseLost)
+	require.NoError(t, a.Release(ctx, id, 200))
+	owner, err := mr.Get(workerKey(id))
+	require.NoError(t, err)
+	assert.Equal(t, "b", owner)
+	saved, err := mr.Get(workerLastTimeKey(id))
+	require.NoError(t, err)
+	assert.Equal(t, "100", saved)
+}
+
+func TestProducer_Heartbeat(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+
+	testCases := []struct {
+		name string
+		// 心跳启动后对Redis的操作
+		after    func(mr *miniredis.Miniredis, id int64)
+		cancel   bool
+		wantLost bool
+	}{
+		{
+			name: "renewed",
+			after: func(mr *miniredis.Miniredis, id int64) {
+				time.Sleep(2 * ttl)
+			},
+		},
+		{
+			name: "taken over",
+			after: func(mr *miniredis.Miniredis, id int64) {
+				_ = mr.Set(workerKey(id), "other")
+			},
+			wantLost: true,
+		},
+		{
+			name: "redis unavailable",
+			after: func(mr *miniredis.Miniredis, id int64) {
+				mr.Close()
+			},
+			wantLost: true,
+		},
+		{
+			name:   "stopped",
+			cancel: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr, client := testutil.NewRedis(t)
+			lease := NewWorkerLease(client, "a", ttl)
+			p := NewLeasedProducer(lease, 1)
+
+			id, _, err := lease.Acquire(context.Background())
+			require.NoError(t, err)
+			gen, err := NewSnowflake(id)
+			require.NoError(t, err)
+			_, err = gen.NextID()
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			lost := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.heartbeat(ctx, gen, lost)
+			}()
+
+			if tc.after != nil {
+				tc.after(mr, id)
+			}
+			if tc.cancel {
+				cancel()
+			}
+
+			if tc.wantLost {
+				select {
+				case <-lost:
+				case <-time.After(5 * ttl):
+					t.Fatal("租约失效后没有通知生产者")
+				}
+				<-done
+				return
+			}
+
+			if tc.cancel {
+				select {
+				case <-done:
+				case <-time.After(5 * ttl):
+					t.Fatal("ctx取消后心跳没有退出")
+				}
+				select {
+				case <-lost:
+					t.Fatal("主动停止时不应该通知生产者")
+				default:
+				}
+				return
+			}
+
+			// 心跳按时续约并记录最后一次生成ID的时间戳
+			saved, err := mr.Get(workerLastTimeKey(id))
+			require.NoError(t, err)
+			assert.Equal(t, strconv.FormatInt(gen.LastTime(), 10), saved)
+			assert.Equal(t, ttl, mr.TTL(workerKey(id)))
+			select {
+			case <-lost:
+				t.Fatal("续约成功时不应该通知生产者")
+			default:
+			}
+		})
+	}
+}
+
+func TestProducer_LeaseLost(t *testing.T) {
+	mr, client := testutil.NewRedis(t)
+	p := NewLeasedProducer(NewWorkerLease(client, "a", 300*time.Millisecond), 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+
+	id := <-p.C() >> sequenceBits & MaxWorkerID
+	require.NoError(t, mr.Set(workerKey(id), "other"))
+
+	// 租约被接管后停止使用原机器ID，重新租用其他机器ID继续生成
+	require.Eventually(t, func() bool {
+		return p.Stats().LeaseLost == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return <-p.C()>>sequenceBits&MaxWorkerID != id
+	}, 3*time.Second, 10*time.Millisecond)
+
+	owner, err := mr.Get(workerKey(id))
+	require.NoError(t, err)
+	assert.Equal(t, "other", owner)
+}
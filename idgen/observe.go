@@ -0,0 +1,74 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"github.com/gotomicro/ego/core/emetric"
+)
+
+const (
+	metricNamespace = "generator"
+	metricSubsystem = "idgen"
+)
+
+var (
+	// generatedCounter 已经写入通道的ID数量，按时间求导即可得到生成速率
+	generatedCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "generated_total",
+		Help:      "已经生成并写入通道的雪花ID数量",
+	}.Build().WithLabelValues()
+
+	// starvedCounter 生产者写入时通道为空的次数，持续增长说明消费速度超过了生成速度
+	starvedCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "starved_total",
+		Help:      "生产者写入时ID通道为空的次数",
+	}.Build().WithLabelValues()
+
+	// clockBackwardsCounter 时钟回拨的次数
+	clockBackwardsCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "clock_backwards_total",
+		Help:      "生成雪花ID时检测到时钟回拨的次数",
+	}.Build().WithLabelValues()
+
+	// errorCounter 生成失败和租用机器ID失败的次数
+	errorCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "errors_total",
+		Help:      "生成雪花ID或者租用机器ID失败的次数",
+	}.Build().WithLabelValues()
+
+	// leaseLostCounter 机器ID租约失效的次数
+	leaseLostCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "lease_lost_total",
+		Help:      "机器ID租约失效的次数",
+	}.Build().WithLabelValues()
+
+	// bufferedGauge 通道中已经生成还没有被消费的ID数量
+	bufferedGauge = emetric.GaugeVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "buffered",
+		Help:      "ID通道中等待消费的雪花ID数量",
+	}.Build().WithLabelValues()
+)
@@ -0,0 +1,72 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// metricValue 读取计数器或者仪表盘的当前值
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	var res dto.Metric
+	require.NoError(t, m.Write(&res))
+	if res.Counter != nil {
+		return res.GetCounter().GetValue()
+	}
+	return res.GetGauge().GetValue()
+}
+
+func TestProducer_Metrics(t *testing.T) {
+	var now atomic.Int64
+	now.Store(time.Now().UnixMilli())
+	gen, err := NewSnowflake(1, WithMaxBackward(10*time.Millisecond))
+	require.NoError(t, err)
+	gen.now = func() time.Time { return time.UnixMilli(now.Load()) }
+
+	// 指标是全局注册的，只比较本次执行前后的差值
+	generated, backwards, errs := metricValue(t, generatedCounter), metricValue(t, clockBackwardsCounter),
+		metricValue(t, errorCounter)
+
+	p := NewProducer(gen, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Start(ctx)
+	for i := 0; i < 20; i++ {
+		<-p.C()
+	}
+
+	// 时钟回拨超出范围时生成失败
+	now.Add(-time.Second.Milliseconds())
+	assert.Eventually(t, func() bool {
+		return metricValue(t, errorCounter) > errs
+	}, time.Second, time.Millisecond)
+	cancel()
+	for range p.C() {
+	}
+
+	stats := p.Stats()
+	assert.GreaterOrEqual(t, stats.Generated, int64(20))
+	assert.Equal(t, float64(stats.Generated), metricValue(t, generatedCounter)-generated)
+	assert.Equal(t, float64(stats.ClockBackwards), metricValue(t, clockBackwardsCounter)-backwards)
+	assert.Equal(t, float64(stats.Errors), metricValue(t, errorCounter)-errs)
+	assert.LessOrEqual(t, metricValue(t, bufferedGauge), float64(8))
+}
@@ -0,0 +1,247 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultBufferSize 默认的ID通道缓冲大小
+	DefaultBufferSize = 4096
+	// leaseRetryInterval 租用机器ID失败后的重试间隔
+	leaseRetryInterval = time.Second
+)
+
+// Stats ID生成的统计数据，计数都是累计值，按时间求导即可得到生成速率，同时通过Prometheus指标暴露
+type Stats struct {
+	// 已经生成的ID数量
+	Generated int64
+	// 生产者写入时通道为空的次数，持续增长说明消费速度超过了生成速度
+	Starved int64
+	// 时钟回拨的次数
+	ClockBackwards int64
+	// 生成失败的次数
+	Errors int64
+	// 机器ID租约失效的次数
+	LeaseLost int64
+}
+
+// Producer 后台goroutine持续生成雪花ID写入带缓冲的通道，通道可以直接作为service.NewService的idCh。
+// 使用租约时机器ID从Redis租用，租约失效后立即停止生成并重新租用新的机器ID
+type Producer struct {
+	// 当前使用的生成器
+	gen atomic.Pointer[Snowflake]
+	// 机器ID租约，为nil时使用固定的机器ID
+	lease *WorkerLease
+	// 租用到机器ID后创建生成器的配置
+	opts []SnowflakeOption
+	ch   chan int64
+	// 统计数据
+	generated atomic.Int64
+	starved   atomic.Int64
+	errors    atomic.Int64
+	leaseLost atomic.Int64
+	// 已经替换掉的生成器累计的时钟回拨次数
+	backwards atomic.Int64
+	// 日志
+	el *elog.Component
+}
+
+// NewProducer 使用固定机器ID的生产者，需要自行保证机器ID在所有节点中唯一
+func NewProducer(gen *Snowflake, bufferSize int) *Producer {
+	p := &Producer{
+		ch: make(chan int64, bufferSize),
+		el: elog.DefaultLogger,
+	}
+	p.gen.Store(gen)
+
+	return p
+}
+
+// NewLeasedProducer 从Redis租用机器ID的生产者，适合副本数量动态变化的部署方式
+func NewLeasedProducer(lease *WorkerLease, bufferSize int, opts ...SnowflakeOption) *Producer {
+	return &Producer{
+		lease: lease,
+		opts:  opts,
+		ch:    make(chan int64, bufferSize),
+		el:    elog.DefaultLogger,
+	}
+}
+
+// C 返回ID通道，生产者退出后通道会被关闭
+func (p *Producer) C() <-chan int64 {
+	return p.ch
+}
+
+// Start 启动生产者goroutine，ctx取消后退出并关闭通道
+func (p *Producer) Start(ctx context.Context) {
+	go p.run(ctx)
+}
+
+func (p *Producer) run(ctx context.Context) {
+	defer close(p.ch)
+
+	if p.lease == nil {
+		p.produce(ctx, p.gen.Load(), nil)
+		return
+	}
+
+	for ctx.Err() == nil {
+		err := p.runLease(ctx)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+
+		p.errors.Add(1)
+		errorCounter.Inc()
+		p.el.Error("机器ID租约异常，重新租用", elog.FieldErr(err))
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(leaseRetryInterval):
+		}
+	}
+}
+
+// runLease 租用机器ID并持续生成，直到ctx取消或者租约失效
+func (p *Producer) runLease(ctx context.Context) error {
+	workerID, lastTime, err := p.lease.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	gen, err := NewSnowflake(workerID, append(p.opts, WithLastTime(lastTime))...)
+	if err != nil {
+		_ = p.lease.Release(ctx, workerID, lastTime)
+		return err
+	}
+
+	if old := p.gen.Swap(gen); old != nil {
+		p.backwards.Add(old.backwards.Load())
+	}
+
+	hbCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := make(chan struct{})
+	go p.heartbeat(hbCtx, gen, lost)
+
+	p.produce(ctx, gen, lost)
+
+	select {
+	case <-lost:
+		p.leaseLost.Add(1)
+		leaseLostCounter.Inc()
+		return ErrLeaseLost
+	default:
+	}
+
+	// 正常退出时主动释放租约，其他节点可以立即复用
+	return p.lease.Release(context.Background(), workerID, gen.LastTime())
+}
+
+// heartbeat 按照租约有效期的1/3续约，租约被其他节点占用，或者连续续约失败导致剩余有效期
+// 不足一个续约周期时关闭lost，通知生产者停止使用当前的机器ID
+func (p *Producer) heartbeat(ctx context.Context, gen *Snowflake, lost chan<- struct{}) {
+	interval := p.lease.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(p.lease.ttl)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := p.lease.Renew(ctx, gen.WorkerID(), gen.LastTime())
+			switch {
+			case err == nil:
+				deadline = time.Now().Add(p.lease.ttl)
+			case errors.Is(err, ErrLeaseLost):
+				close(lost)
+				return
+			case ctx.Err() != nil:
+				// 生产者退出时取消续约，不是续约失败
+				return
+			default:
+				p.el.Error("机器ID续约失败",
+					elog.FieldErr(err),
+					elog.Int64("workerID", gen.WorkerID()))
+				if time.Until(deadline) < interval {
+					close(lost)
+					return
+				}
+			}
+		}
+	}
+}
+
+// produce 使用gen持续生成ID写入通道，直到ctx取消或者lost被关闭
+func (p *Producer) produce(ctx context.Context, gen *Snowflake, lost <-chan struct{}) {
+	for {
+		id, err := gen.NextID()
+		if err != nil {
+			p.errors.Add(1)
+			errorCounter.Inc()
+			p.el.Error("生成雪花ID失败", elog.FieldErr(err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-lost:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			continue
+		}
+
+		if len(p.ch) == 0 {
+			p.starved.Add(1)
+			starvedCounter.Inc()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-lost:
+			return
+		case p.ch <- id:
+			p.generated.Add(1)
+			generatedCounter.Inc()
+			bufferedGauge.Set(float64(len(p.ch)))
+		}
+	}
+}
+
+func (p *Producer) Stats() Stats {
+	backwards := p.backwards.Load()
+	if gen := p.gen.Load(); gen != nil {
+		backwards += gen.backwards.Load()
+	}
+
+	return Stats{
+		Generated:      p.generated.Load(),
+		Starved:        p.starved.Load(),
+		ClockBackwards: backwards,
+		Errors:         p.errors.Load(),
+		LeaseLost:      p.leaseLost.Load(),
+	}
+}
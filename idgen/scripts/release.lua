@@ -0,0 +1,19 @@
+-- 释放机器ID租约
+-- 1. 只有租约的持有者才能释放
+-- 2. 释放前记录最后一次生成ID的时间戳
+
+local workerKey = KEYS[1]
+local lastTimeKey = KEYS[2]
+local owner = ARGV[1]
+local lastTime = tonumber(ARGV[2])
+
+if redis.call("GET", workerKey) ~= owner then
+	return 0
+end
+
+local saved = tonumber(redis.call("GET", lastTimeKey) or "0")
+if lastTime > saved then
+	redis.call("SET", lastTimeKey, lastTime)
+end
+
+return redis.call("DEL", workerKey)
@@ -0,0 +1,21 @@
+-- 机器ID续约
+-- 1. 只有租约的持有者才能续约
+-- 2. 记录最后一次生成ID的时间戳，时间戳只增不减
+
+local workerKey = KEYS[1]
+local lastTimeKey = KEYS[2]
+local owner = ARGV[1]
+local ttl = tonumber(ARGV[2])
+local lastTime = tonumber(ARGV[3])
+
+if redis.call("GET", workerKey) ~= owner then
+	return 0
+end
+
+redis.call("PEXPIRE", workerKey, ttl)
+local saved = tonumber(redis.call("GET", lastTimeKey) or "0")
+if lastTime > saved then
+	redis.call("SET", lastTimeKey, lastTime)
+end
+
+return 1
@@ -0,0 +1,156 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	workerIDBits = 10
+	sequenceBits = 12
+	// MaxWorkerID 最大的机器ID
+	MaxWorkerID = 1<<workerIDBits - 1
+	maxSequence = 1<<sequenceBits - 1
+	// DefaultMaxBackward 默认允许的时钟回拨时间，回拨在此范围内时等待时钟追上
+	DefaultMaxBackward = 5 * time.Millisecond
+)
+
+// DefaultEpoch 默认的起始时间2025-01-01 00:00:00 UTC，41位时间戳可以使用约69年
+var DefaultEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrInvalidWorkerID = errors.New("机器ID超出范围")
+	ErrClockBackwards  = errors.New("时钟回拨超过允许的范围")
+)
+
+// Snowflake 雪花ID生成器，ID由41位毫秒时间戳、10位机器ID和12位序列号组成，
+// 同一毫秒内序列号用完后等待下一毫秒
+type Snowflake struct {
+	mu sync.Mutex
+	// 起始时间的毫秒时间戳
+	epoch int64
+	// 机器ID
+	workerID int64
+	// 上一次生成ID的毫秒时间戳
+	lastTime int64
+	// 当前毫秒内的序列号
+	sequence int64
+	// 允许的时钟回拨时间
+	maxBackward time.Duration
+	// 时钟回拨的次数
+	backwards atomic.Int64
+	now       func() time.Time
+}
+
+type SnowflakeOption func(s *Snowflake)
+
+// WithEpoch 设置起始时间，所有节点必须一致
+func WithEpoch(epoch time.Time) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.epoch = epoch.UnixMilli()
+	}
+}
+
+// WithMaxBackward 设置允许的时钟回拨时间
+func WithMaxBackward(d time.Duration) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.maxBackward = d
+	}
+}
+
+func NewSnowflake(workerID int64, opts ...SnowflakeOption) (*Snowflake, error) {
+	if workerID < 0 || workerID > MaxWorkerID {
+		return nil, ErrInvalidWorkerID
+	}
+
+	s := &Snowflake{
+		epoch:       DefaultEpoch.UnixMilli(),
+		workerID:    workerID,
+		maxBackward: DefaultMaxBackward,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// WithLastTime 设置上一次生成ID的毫秒时间戳，机器ID从其他节点接管时传入原节点最后的时间戳，
+// 本节点的时钟落后于原节点时不会生成重复的ID
+func WithLastTime(lastTime int64) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.lastTime = lastTime
+	}
+}
+
+// WorkerID 当前生成器使用的机器ID
+func (s *Snowflake) WorkerID() int64 {
+	return s.workerID
+}
+
+// LastTime 最后一次生成ID的毫秒时间戳
+func (s *Snowflake) LastTime() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastTime
+}
+
+// NextID 生成一个新的ID，时钟回拨在允许范围内时等待时钟追上，超出范围时返回ErrClockBackwards
+func (s *Snowflake) NextID() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UnixMilli()
+	if now < s.lastTime {
+		s.backwards.Add(1)
+		clockBackwardsCounter.Inc()
+		backward := time.Duration(s.lastTime-now) * time.Millisecond
+		if backward > s.maxBackward {
+			return 0, ErrClockBackwards
+		}
+
+		time.Sleep(backward)
+		now = s.waitAfter(s.lastTime - 1)
+	}
+
+	if now == s.lastTime {
+		s.sequence = (s.sequence + 1) & maxSequence
+		if s.sequence == 0 {
+			now = s.waitAfter(s.lastTime)
+		}
+	} else {
+		s.sequence = 0
+	}
+
+	s.lastTime = now
+	return (now-s.epoch)<<(workerIDBits+sequenceBits) | s.workerID<<sequenceBits | s.sequence, nil
+}
+
+// waitAfter 自旋等待到last之后的下一毫秒
+func (s *Snowflake) waitAfter(last int64) int64 {
+	now := s.now().UnixMilli()
+	for now <= last {
+		time.Sleep(100 * time.Microsecond)
+		now = s.now().UnixMilli()
+	}
+
+	return now
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnowflake_NextID(t *testing.T) {
+	s, err := NewSnowflake(3)
+	require.NoError(t, err)
+
+	var last int64
+	seen := make(map[int64]struct{}, 100000)
+	for i := 0; i < 100000; i++ {
+		id, er := s.NextID()
+		require.NoError(t, er)
+		assert.Greater(t, id, last)
+		assert.Equal(t, int64(3), id>>sequenceBits&MaxWorkerID)
+		seen[id] = struct{}{}
+		last = id
+	}
+	assert.Len(t, seen, 100000)
+}
+
+func TestSnowflake_ClockBackwards(t *testing.T) {
+	now := time.Now()
+	s, err := NewSnowflake(1, WithMaxBackward(10*time.Millisecond))
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+
+	_, err = s.NextID()
+	require.NoError(t, err)
+
+	now = now.Add(-time.Second)
+	_, err = s.NextID()
+	assert.ErrorIs(t, err, ErrClockBackwards)
+	assert.Equal(t, int64(1), s.backwards.Load())
+}
+
+func TestNewSnowflake(t *testing.T) {
+	_, err := NewSnowflake(MaxWorkerID + 1)
+	assert.ErrorIs(t, err, ErrInvalidWorkerID)
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil 测试共用的sqlite分库分表、本地消息表、Redis和ID通道
+package testutil
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/dao"
+	lmt "github.com/TimeWtr/local_message_table"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TxPusher 直接在绑定的库上开启事务，模拟部署在分库上的本地消息表
+type TxPusher struct {
+	DB *gorm.DB
+}
+
+func (p TxPusher) ExecTo(ctx context.Context, fn func(ctx context.Context, tx *gorm.DB) ([]lmt.Messages, error), _ any) error {
+	return p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := fn(ctx, tx)
+		return err
+	})
+}
+
+// Pusher 获取在分库db上开启事务的本地消息表，可以直接作为repository.PusherFunc
+func Pusher(db *gorm.DB) repository.MessagePusher {
+	return TxPusher{DB: db}
+}
+
+// Recorder 记录事务提交后写入本地消息表的消息，事务回滚时不记录
+type Recorder struct {
+	mu   sync.Mutex
+	msgs []lmt.Messages
+}
+
+// Pusher 获取在分库db上开启事务并记录消息的本地消息表，可以直接作为repository.PusherFunc
+func (r *Recorder) Pusher(db *gorm.DB) repository.MessagePusher {
+	return recordPusher{db: db, r: r}
+}
+
+// Messages 已经提交的消息
+func (r *Recorder) Messages() []lmt.Messages {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.msgs)
+}
+
+type recordPusher struct {
+	db *gorm.DB
+	r  *Recorder
+}
+
+func (p recordPusher) ExecTo(ctx context.Context, fn func(ctx context.Context, tx *gorm.DB) ([]lmt.Messages, error), _ any) error {
+	var msgs []lmt.Messages
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		msgs, err = fn(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	p.r.mu.Lock()
+	defer p.r.mu.Unlock()
+	p.r.msgs = append(p.r.msgs, msgs...)
+	return nil
+}
+
+// OpenSqlite 在测试的临时目录中创建sqlite库，并发写入时等待锁释放而不是直接返回database is locked
+func OpenSqlite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db?_busy_timeout=5000"),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	return db
+}
+
+// NewShards 创建n个分表short_code_0...，每个分表使用单独的sqlite库，sqlite的索引名在库内全局唯一，
+// 同一个库中无法创建多个分表
+func NewShards(t *testing.T, n int) (data_source.Factory, []*gorm.DB) {
+	dss := make([]data_source.DataSource, 0, n)
+	dbs := make([]*gorm.DB, 0, n)
+	for i := 0; i < n; i++ {
+		db := OpenSqlite(t)
+		require.NoError(t, db.Table(fmt.Sprintf("short_code_%d", i)).AutoMigrate(&dao.ShortCode{}))
+		dss = append(dss, data_source.DataSource{DB: db, TableCount: 1})
+		dbs = append(dbs, db)
+	}
+
+	return data_source.NewHashDataFactory(dss, n, "short_code_"), dbs
+}
+
+// NewIDCh 按顺序产生ID的通道，测试结束后停止
+func NewIDCh(t *testing.T) <-chan int64 {
+	ch := make(chan int64)
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+	})
+
+	go func() {
+		for id := int64(1); ; id++ {
+			select {
+			case ch <- id:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// NewRedis 启动miniredis并返回客户端，测试结束后关闭
+func NewRedis(t *testing.T) (*miniredis.Miniredis, redis.Cmdable) {
+	mr := miniredis.RunT(t)
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache/bloom"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+// BloomRebuildJob 从所有分库分表中全量扫描短码重建过滤器，用于Redis数据丢失后的恢复，
+// 或者过滤器层数过多、误判率升高时的轮换。短码池中尚未分配的预生成短码也会写入新的过滤器
+type BloomRebuildJob struct {
+	// 分库分表
+	f data_source.Factory
+	// 可扩展过滤器
+	bf *bloom.Scalable
+	// 短码池所在的Redis
+	client redis.Cmdable
+	// 每批次扫描的数量
+	batchSize int
+	// 日志
+	el *elog.Component
+}
+
+func NewBloomRebuildJob(f data_source.Factory, bf *bloom.Scalable,
+	client redis.Cmdable, batchSize int) *BloomRebuildJob {
+	return &BloomRebuildJob{
+		f:         f,
+		bf:        bf,
+		client:    client,
+		batchSize: batchSize,
+		el:        elog.DefaultLogger,
+	}
+}
+
+func (b *BloomRebuildJob) Run(ctx context.Context) error {
+	return b.bf.Rebuild(ctx, cache.BFKey, func(yield func(data []any) error) error {
+		for _, dst := range b.f.AllDst() {
+			if err := b.scanTable(ctx, dst, yield); err != nil {
+				return err
+			}
+		}
+
+		return b.scanPool(ctx, yield)
+	})
+}
+
+// scanTable 按照主键分批扫描分表中的短码，软删除的短码仍然占用唯一索引，同样需要写入
+func (b *BloomRebuildJob) scanTable(ctx context.Context, dst data_source.Dst, yield func(data []any) error) error {
+	var lastID int64
+	for {
+		var rows []dao.ShortCode
+		err := dst.DB.WithContext(ctx).
+			Table(dst.Table).
+			Select("id", "short_code").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(b.batchSize).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		data := make([]any, len(rows))
+		for i, row := range rows {
+			data[i] = row.ShortCode
+		}
+
+		if err = yield(data); err != nil {
+			return err
+		}
+
+		b.el.Info("过滤器重建扫描分表",
+			elog.String("table", dst.Table),
+			elog.Int64("lastID", lastID))
+
+		if len(rows) < b.batchSize {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// scanPool 分批读取短码池中的预生成短码
+func (b *BloomRebuildJob) scanPool(ctx context.Context, yield func(data []any) error) error {
+	for start := int64(0); ; start += int64(b.batchSize) {
+		codes, err := b.client.LRange(ctx, cache.PoolKey, start, start+int64(b.batchSize)-1).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(codes) == 0 {
+			return nil
+		}
+
+		data := make([]any, len(codes))
+		for i, code := range codes {
+			data[i] = code
+		}
+
+		if err = yield(data); err != nil {
+			return err
+		}
+
+		if len(codes) < b.batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache/base62"
+	"github.com/TimeWtr/generator/repository/cache/bloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestBloomRebuildJob_Run(t *testing.T) {
+	f, _ := testutil.NewShards(t, 2)
+	repo := repository.NewGeneratorRepository(f, testutil.Pusher)
+	_, client := testutil.NewRedis(t)
+	ctx := context.Background()
+
+	cfg := bloom.DefaultScalableConfig()
+	cfg.Capacity = 1000
+	bf := bloom.NewScalable(client, cfg, func(cfg bloom.Config) cache.BFCache {
+		b, err := bloom.NewBitsetBloom(client, cfg)
+		require.NoError(t, err)
+		return b
+	})
+	require.NoError(t, bf.Reserve(ctx, cache.BFKey))
+	require.NoError(t, bf.Add(ctx, cache.BFKey, "stale"))
+
+	var want []any
+	var data []domain.URLData
+	for i := 1; i <= 7; i++ {
+		code := fmt.Sprintf("code%d", i)
+		data = append(data, domain.URLData{ID: int64(i), Biz: "test", ShortCode: code})
+		want = append(want, code)
+	}
+	require.NoError(t, repo.BatchInsert(ctx, data))
+	// 软删除的短码仍然占用唯一索引
+	for _, d := range repo.Shards() {
+		require.NoError(t, d.Delete(ctx, 3))
+	}
+
+	pool := []string{"pool1", "pool2", "pool3"}
+	require.NoError(t, base62.NewCacheBase62(client).BatchInsertShortCodes(ctx, pool))
+	for _, code := range pool {
+		want = append(want, code)
+	}
+
+	require.NoError(t, NewBloomRebuildJob(f, bf, client, 2).Run(ctx))
+
+	res, err := bf.MExists(ctx, cache.BFKey, append(want, "stale"))
+	require.NoError(t, err)
+	for _, code := range want {
+		assert.True(t, res[code.(string)], code)
+	}
+	assert.False(t, res["stale"])
+}
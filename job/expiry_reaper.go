@@ -0,0 +1,310 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/TimeWtr/generator/event"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/dao"
+	lmt "github.com/TimeWtr/local_message_table"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultReapBatchSize 默认每批次清理的过期短链数量
+	DefaultReapBatchSize = 500
+	// DefaultReapInterval 默认的清理间隔
+	DefaultReapInterval = 10 * time.Minute
+	// DefaultReapGrace 默认的过期短码隔离期，需要大于跳转服务缓存短码映射的时间
+	DefaultReapGrace = 7 * 24 * time.Hour
+	// DefaultReapLockKey 过期清理任务分布式锁的前缀，每个分表单独加锁
+	DefaultReapLockKey = "ShortCodeExpiryReaperLock"
+	// DefaultReapLockExpiration 默认的锁过期时间，需要大于清理一个分表的时间
+	DefaultReapLockExpiration = 5 * time.Minute
+)
+
+// ReapMode 过期短链的清理方式
+type ReapMode uint8
+
+const (
+	// ReapArchive 软删除过期短链，记录保留到隔离期结束后由回收任务物理删除
+	ReapArchive ReapMode = iota
+	// ReapDelete 直接物理删除过期短链
+	ReapDelete
+)
+
+// ExpiryReaperConfig 过期清理任务的配置
+type ExpiryReaperConfig struct {
+	// 清理方式
+	Mode ReapMode
+	// 每批次清理的数量
+	BatchSize int
+	// 清理间隔
+	Interval time.Duration
+	// 过期短码的隔离期，隔离期结束后由回收任务归还到短码池
+	Grace time.Duration
+	// 分布式锁的前缀
+	LockKey string
+	// 分布式锁的过期时间
+	LockExpiration time.Duration
+}
+
+func DefaultExpiryReaperConfig() ExpiryReaperConfig {
+	return ExpiryReaperConfig{
+		Mode:           ReapArchive,
+		BatchSize:      DefaultReapBatchSize,
+		Interval:       DefaultReapInterval,
+		Grace:          DefaultReapGrace,
+		LockKey:        DefaultReapLockKey,
+		LockExpiration: DefaultReapLockExpiration,
+	}
+}
+
+// ExpiryReaper 过期短链清理任务，按分表加锁，多个实例可以同时清理不同的分表。每个分表按照主键分批扫描过期短链，
+// 删除和过期事件在同一个本地消息表事务中完成，跳转服务消费事件后清理缓存。开启回收后过期短码放入隔离区，
+// 隔离期结束后由RecycleJob归还到短码池，自定义短码不会被回收
+type ExpiryReaper struct {
+	cfg ExpiryReaperConfig
+	// 分库分表
+	f data_source.Factory
+	// 获取分库上的本地消息表
+	pusher repository.PusherFunc
+	// 分布式锁
+	locker Locker
+	// 消息ID获取的通道
+	idCh <-chan int64
+	// 短码隔离区，为nil时过期短码不回收
+	rc cache.RecycleCache
+	// 短码映射缓存，为nil时不清理
+	mc cache.MappingCache
+	// 日志
+	el *elog.Component
+}
+
+func NewExpiryReaper(cfg ExpiryReaperConfig, f data_source.Factory, pusher repository.PusherFunc, locker Locker,
+	idCh <-chan int64, rc cache.RecycleCache, mc cache.MappingCache) *ExpiryReaper {
+	return &ExpiryReaper{
+		cfg:    cfg,
+		f:      f,
+		pusher: pusher,
+		locker: locker,
+		idCh:   idCh,
+		rc:     rc,
+		mc:     mc,
+		el:     elog.DefaultLogger,
+	}
+}
+
+// Start 按照间隔循环清理，直到ctx被取消
+func (r *ExpiryReaper) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Run(ctx); err != nil {
+				r.el.Error("清理过期短链失败", elog.FieldErr(err))
+			}
+		}
+	}
+}
+
+// Run 执行一轮清理，单个分表失败不影响其他分表，返回所有分表的错误
+func (r *ExpiryReaper) Run(ctx context.Context) error {
+	var errs []error
+	for _, dst := range r.f.AllDst() {
+		if err := r.reapTable(ctx, dst); err != nil {
+			errs = append(errs, err)
+			r.el.Error("清理分表的过期短链失败",
+				elog.FieldErr(err),
+				elog.String("table", dst.Table))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// reapTable 清理一个分表，其他实例正在清理时直接返回
+func (r *ExpiryReaper) reapTable(ctx context.Context, dst data_source.Dst) error {
+	unlock, ok, err := r.locker.TryLock(ctx, r.cfg.LockKey+":"+dst.Table, r.cfg.LockExpiration)
+	if err != nil || !ok {
+		return err
+	}
+	defer func() {
+		if er := unlock(context.Background()); er != nil {
+			r.el.Error("释放过期清理任务的锁失败", elog.FieldErr(er))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.LockExpiration)
+	defer cancel()
+
+	now := time.Now().UnixMilli()
+	d := dao.NewShardShortCodeDao(dst.DB, dst.Table)
+	var lastID int64
+	for {
+		rows, er := d.ListExpired(ctx, now, lastID, r.cfg.BatchSize)
+		if er != nil {
+			return er
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+
+		codes, recyclable, er := r.reapBatch(ctx, dst, rows, now)
+		if er != nil {
+			return er
+		}
+		r.recycle(ctx, codes, recyclable)
+
+		if len(rows) < r.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// reapBatch 删除一批过期短链并生成过期事件，在分表所在库的本地消息表事务中执行，保证删除和消息在同一个库的事务中，
+// 返回删除的短码和其中可以回收的短码
+func (r *ExpiryReaper) reapBatch(ctx context.Context, dst data_source.Dst,
+	rows []dao.ShortCode, now int64) ([]string, []string, error) {
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+
+	var codes, recyclable []string
+	fn := func(ctx context.Context, tx *gorm.DB) ([]lmt.Messages, error) {
+		if !dst.Owns(tx) {
+			return nil, fmt.Errorf("%w: 事务不是在分表%s的库上开启的", generator.ErrShardingFailed, dst.Table)
+		}
+
+		d := dao.NewShardShortCodeDao(tx, dst.Table)
+		expired, er := d.DeleteExpired(ctx, ids, now)
+		if er != nil {
+			return nil, er
+		}
+
+		codes = make([]string, 0, len(expired))
+		recyclable = make([]string, 0, len(expired))
+		for _, sc := range expired {
+			codes = append(codes, sc.ShortCode)
+			if !sc.Custom {
+				recyclable = append(recyclable, sc.ShortCode)
+			}
+		}
+
+		if r.cfg.Mode == ReapDelete {
+			if er = d.Purge(ctx, codes); er != nil {
+				return nil, er
+			}
+		}
+
+		msgs := make([]lmt.Messages, 0, len(expired))
+		for _, sc := range expired {
+			msg, er := r.message(ctx, sc)
+			if er != nil {
+				return nil, er
+			}
+			msgs = append(msgs, msg)
+		}
+
+		return msgs, nil
+	}
+
+	if err := r.pusher(dst.DB).ExecTo(ctx, fn, rows[0].ShortCode); err != nil {
+		return nil, nil, err
+	}
+
+	return codes, recyclable, nil
+}
+
+func (r *ExpiryReaper) message(ctx context.Context, sc dao.ShortCode) (lmt.Messages, error) {
+	id, err := r.nextID(ctx)
+	if err != nil {
+		return lmt.Messages{}, err
+	}
+
+	content, err := json.Marshal(event.URLEvent{
+		Type:      event.URLEventExpire,
+		ID:        sc.ID,
+		Biz:       sc.Biz,
+		ShortCode: sc.ShortCode,
+		ExpireAt:  sc.ExpireAt,
+	})
+	if err != nil {
+		return lmt.Messages{}, err
+	}
+
+	return lmt.Messages{
+		ID:        id,
+		Biz:       sc.Biz,
+		MessageID: "exp-" + strconv.FormatInt(id, 10),
+		Topic:     generator.Topic,
+		Content:   string(content),
+		Status:    lmt.MessageStatusNotSend.Int(),
+	}, nil
+}
+
+// recycle 清理映射缓存并将可以回收的过期短码放入隔离区，自定义短码不归还到短码池，删除已经成功，失败只记录日志
+func (r *ExpiryReaper) recycle(ctx context.Context, codes []string, recyclable []string) {
+	if len(codes) == 0 {
+		return
+	}
+
+	if r.mc != nil {
+		if err := r.mc.Del(ctx, codes...); err != nil {
+			r.el.Error("清理过期短码的映射缓存失败", elog.FieldErr(err))
+		}
+	}
+
+	if r.rc == nil || len(recyclable) == 0 {
+		return
+	}
+
+	releaseAt := time.Now().Add(r.cfg.Grace).UnixMilli()
+	if err := r.rc.Quarantine(ctx, releaseAt, recyclable...); err != nil {
+		r.el.Error("过期短码放入隔离区失败",
+			elog.FieldErr(err),
+			elog.Any("codes", recyclable))
+	}
+}
+
+func (r *ExpiryReaper) nextID(ctx context.Context) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case id, ok := <-r.idCh:
+		if !ok {
+			return 0, errors.New("ID通道已关闭")
+		}
+		return id, nil
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache/mapping"
+	"github.com/TimeWtr/generator/repository/cache/recycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+func TestExpiryReaper_Run(t *testing.T) {
+	f, _ := testutil.NewShards(t, 2)
+	repo := repository.NewGeneratorRepository(f, testutil.Pusher)
+	mr, client := testutil.NewRedis(t)
+	mc := mapping.NewCacheMapping(client)
+	ctx := context.Background()
+
+	expireAt := time.Now().Add(-time.Hour).UnixMilli()
+	data := []domain.URLData{
+		{ID: 1, Biz: "test", OriginURL: "https://example.com/1", ShortCode: "expired1", ExpireAt: expireAt},
+		{ID: 2, Biz: "test", OriginURL: "https://example.com/2", ShortCode: "expired2", ExpireAt: expireAt},
+		{ID: 3, Biz: "test", OriginURL: "https://example.com/3", ShortCode: "vanity", ExpireAt: expireAt, Custom: true},
+		{ID: 4, Biz: "test", OriginURL: "https://example.com/4", ShortCode: "alive", ExpireAt: time.Now().Add(time.Hour).UnixMilli()},
+		{ID: 5, Biz: "test", OriginURL: "https://example.com/5", ShortCode: "forever"},
+	}
+	require.NoError(t, repo.BatchInsert(ctx, data))
+	for _, d := range data {
+		require.NoError(t, mc.Set(ctx, d.ShortCode, cache.Mapping{Biz: d.Biz, OriginalURL: d.OriginURL}, time.Hour))
+	}
+
+	cfg := DefaultExpiryReaperConfig()
+	cfg.BatchSize = 1
+	r := NewExpiryReaper(cfg, f, testutil.Pusher, NewRedisLocker(client), testutil.NewIDCh(t), recycle.NewCacheRecycle(client), mc)
+	require.NoError(t, r.Run(ctx))
+
+	for _, code := range []string{"expired1", "expired2", "vanity"} {
+		d, err := repo.Shard("test", code)
+		require.NoError(t, err)
+		_, err = d.GetURLByShortCode(ctx, code)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, code)
+		_, err = mc.Get(ctx, code)
+		assert.ErrorIs(t, err, cache.ErrMappingMiss, code)
+	}
+
+	for _, code := range []string{"alive", "forever"} {
+		d, err := repo.Shard("test", code)
+		require.NoError(t, err)
+		_, err = d.GetURLByShortCode(ctx, code)
+		assert.NoError(t, err, code)
+	}
+
+	// 自定义短码不放入隔离区
+	members, err := mr.ZMembers(cache.RecycleKey)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"expired1", "expired2"}, members)
+}
+
+func TestExpiryReaper_ShardMismatch(t *testing.T) {
+	f, dbs := testutil.NewShards(t, 2)
+	repo := repository.NewGeneratorRepository(f, testutil.Pusher)
+	ctx := context.Background()
+
+	expireAt := time.Now().Add(-time.Hour).UnixMilli()
+	var data []domain.URLData
+	for i := 1; i <= 10; i++ {
+		data = append(data, domain.URLData{
+			ID:        int64(i),
+			Biz:       "test",
+			OriginURL: fmt.Sprintf("https://example.com/%d", i),
+			ShortCode: fmt.Sprintf("code%d", i),
+			ExpireAt:  expireAt,
+		})
+	}
+	require.NoError(t, repo.BatchInsert(ctx, data))
+
+	// 本地消息表总是在第一个库上开启事务，第二个库的分表不能在该事务中删除
+	r := NewExpiryReaper(DefaultExpiryReaperConfig(), f, func(*gorm.DB) repository.MessagePusher {
+		return testutil.TxPusher{DB: dbs[0]}
+	}, newLocker(t), testutil.NewIDCh(t), nil, nil)
+	assert.ErrorIs(t, r.Run(ctx), generator.ErrShardingFailed)
+
+	var count int64
+	require.NoError(t, dbs[1].Table("short_code_1").Where("delete_time = 0").Count(&count).Error)
+	assert.NotZero(t, count)
+}
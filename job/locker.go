@@ -0,0 +1,52 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"errors"
+	"time"
+
+	dislock "github.com/TimeWtr/dis_lock"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+// Locker 分布式锁，多个实例部署时保证同一个定时任务同时只有一个实例在执行
+type Locker interface {
+	// TryLock 尝试加锁，锁被其他实例持有时返回false，加锁成功后通过unlock释放
+	TryLock(ctx context.Context, key string, expiration time.Duration) (unlock func(ctx context.Context) error, ok bool, err error)
+}
+
+// RedisLocker 基于github.com/TimeWtr/dis_lock的Redis分布式锁，每次加锁使用随机的值标识持有者，
+// 只有持有者才能释放锁
+type RedisLocker struct {
+	client *dislock.Client
+}
+
+func NewRedisLocker(client redis.Cmdable) Locker {
+	return &RedisLocker{client: dislock.NewClient(client)}
+}
+
+func (r *RedisLocker) TryLock(ctx context.Context, key string, expiration time.Duration) (func(ctx context.Context) error, bool, error) {
+	lock, err := r.client.TryLock(ctx, key, r.client.UUID(), expiration)
+	if errors.Is(err, dislock.ErrPreemptLock) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return lock.UnLock, true, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// newLocker 使用miniredis的分布式锁
+func newLocker(t *testing.T) Locker {
+	_, client := testutil.NewRedis(t)
+	return NewRedisLocker(client)
+}
+
+func TestRedisLocker_TryLock(t *testing.T) {
+	mr, client := testutil.NewRedis(t)
+	l1, l2 := NewRedisLocker(client), NewRedisLocker(client)
+	ctx := context.Background()
+
+	unlock, ok, err := l1.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// 锁被其他实例持有
+	_, ok, err = l2.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 释放后其他实例可以加锁
+	require.NoError(t, unlock(ctx))
+	unlock, ok, err = l2.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// 锁过期后被其他实例持有，原持有者不能释放
+	mr.FastForward(time.Minute)
+	_, ok, err = l1.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Error(t, unlock(ctx))
+	assert.True(t, mr.Exists("job"))
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultPoolThreshold 短码池中预生成短码数量的默认阈值，低于阈值时开始补充
+	DefaultPoolThreshold = 100000
+	// DefaultPoolBatchSize 默认每批次生成并写入短码池的数量
+	DefaultPoolBatchSize = 1000
+	// DefaultPoolFillInterval 默认的检查间隔
+	DefaultPoolFillInterval = time.Minute
+	// DefaultPoolLockKey 补充短码池任务的分布式锁
+	DefaultPoolLockKey = "ShortCodePoolFillerLock"
+	// DefaultPoolLockExpiration 默认的锁过期时间，需要大于一次补充任务的执行时间
+	DefaultPoolLockExpiration = 5 * time.Minute
+	// DefaultPoolMaxEmptyBatches 默认允许连续被过滤器全部过滤的批次数量
+	DefaultPoolMaxEmptyBatches = 10
+)
+
+// Sequencer 预生成短码使用的递增ID序列
+type Sequencer interface {
+	// Next 申请最多n个连续的递增ID，返回[start, end)，返回前区间必须已经持久化，
+	// 多个实例或者重启后都不会再分配出相同的ID
+	Next(ctx context.Context, n int64) (start int64, end int64, err error)
+}
+
+// Encoder 将递增ID编码为短码
+type Encoder interface {
+	Encode(id int64) (string, error)
+}
+
+// PoolFillerConfig 短码池补充任务的配置
+type PoolFillerConfig struct {
+	// 短码池数量阈值
+	Threshold int64
+	// 每批次生成的数量
+	BatchSize int64
+	// 检查间隔
+	Interval time.Duration
+	// 分布式锁的key
+	LockKey string
+	// 分布式锁的过期时间
+	LockExpiration time.Duration
+	// 允许连续被过滤器全部过滤的批次数量，超过后放弃本次补充，避免过滤器异常时无限消耗递增ID
+	MaxEmptyBatches int
+}
+
+func DefaultPoolFillerConfig() PoolFillerConfig {
+	return PoolFillerConfig{
+		Threshold:       DefaultPoolThreshold,
+		BatchSize:       DefaultPoolBatchSize,
+		Interval:        DefaultPoolFillInterval,
+		LockKey:         DefaultPoolLockKey,
+		LockExpiration:  DefaultPoolLockExpiration,
+		MaxEmptyBatches: DefaultPoolMaxEmptyBatches,
+	}
+}
+
+// PoolFiller 短码池预生成任务，抢占到分布式锁的实例检查短码池中的数量，低于阈值时按批次申请递增ID，
+// 编码为短码并过滤掉过滤器中已经存在的哈希短码和自定义短码后批量写入短码池，直到数量达到阈值。递增ID必须先持久化再写入短码池，以数据库为准，
+// 写入短码池失败最多浪费一批ID，不会产生重复的短码
+type PoolFiller struct {
+	cfg PoolFillerConfig
+	// 分布式锁
+	locker Locker
+	// 短码池
+	pc cache.PoolCache
+	// 短码过滤器
+	bf cache.BFCache
+	// 递增ID序列
+	seq Sequencer
+	// 短码编码
+	enc Encoder
+	// 日志
+	el *elog.Component
+}
+
+func NewPoolFiller(cfg PoolFillerConfig, locker Locker, pc cache.PoolCache, bf cache.BFCache,
+	seq Sequencer, enc Encoder) *PoolFiller {
+	return &PoolFiller{
+		cfg:    cfg,
+		locker: locker,
+		pc:     pc,
+		bf:     bf,
+		seq:    seq,
+		enc:    enc,
+		el:     elog.DefaultLogger,
+	}
+}
+
+// Start 按照间隔循环检查并补充短码池，直到ctx被取消
+func (p *PoolFiller) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Run(ctx); err != nil {
+				p.el.Error("补充短码池失败", elog.FieldErr(err))
+			}
+		}
+	}
+}
+
+// Run 执行一次补充，没有抢占到锁时直接返回
+func (p *PoolFiller) Run(ctx context.Context) error {
+	unlock, ok, err := p.locker.TryLock(ctx, p.cfg.LockKey, p.cfg.LockExpiration)
+	if err != nil || !ok {
+		return err
+	}
+	defer func() {
+		if er := unlock(context.Background()); er != nil {
+			p.el.Error("释放短码池补充任务的锁失败", elog.FieldErr(er))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.LockExpiration)
+	defer cancel()
+
+	count, err := p.pc.Count(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	empty := 0
+	for count < p.cfg.Threshold {
+		codes, er := p.generate(ctx)
+		if er != nil {
+			return er
+		}
+
+		codes, er = p.filter(ctx, codes)
+		if er != nil {
+			return er
+		}
+
+		if len(codes) == 0 {
+			empty++
+			if empty >= p.cfg.MaxEmptyBatches {
+				return fmt.Errorf("连续%d批预生成的短码都已经存在于过滤器中", empty)
+			}
+			continue
+		}
+		empty = 0
+
+		if er = p.pc.BatchInsertShortCodes(ctx, codes); er != nil {
+			return er
+		}
+
+		count += int64(len(codes))
+	}
+
+	return nil
+}
+
+// generate 申请一批已经持久化的递增ID并编码为短码
+func (p *PoolFiller) generate(ctx context.Context) ([]string, error) {
+	start, end, err := p.seq.Next(ctx, p.cfg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if end <= start {
+		return nil, errors.New("递增ID序列没有分配到ID")
+	}
+
+	codes := make([]string, 0, end-start)
+	for id := start; id < end; id++ {
+		code, er := p.enc.Encode(id)
+		if er != nil {
+			return nil, er
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// filter 过滤掉过滤器中可能已经存在的短码，编码得到的短码可能与哈希短码或者自定义短码重复，
+// 重复的短码写入数据库时失败后会被补偿处理器放回短码池，导致反复失败。误判的短码直接丢弃
+func (p *PoolFiller) filter(ctx context.Context, codes []string) ([]string, error) {
+	data := make([]any, len(codes))
+	for i, code := range codes {
+		data[i] = code
+	}
+
+	exists, err := p.bf.MExists(ctx, cache.BFKey, data)
+	if err != nil {
+		return nil, err
+	}
+
+	res := codes[:0]
+	for _, code := range codes {
+		if !exists[code] {
+			res = append(res, code)
+		}
+	}
+
+	if skipped := len(codes) - len(res); skipped > 0 {
+		p.el.Warn("预生成的短码已经存在，跳过", elog.Int("count", skipped))
+	}
+
+	return res, nil
+}
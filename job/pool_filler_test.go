@@ -0,0 +1,173 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/TimeWtr/generator/codec"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache/base62"
+	"github.com/TimeWtr/generator/repository/cache/bloom"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// countingSequencer 记录申请ID的次数
+type countingSequencer struct {
+	Sequencer
+	calls int
+}
+
+func (c *countingSequencer) Next(ctx context.Context, n int64) (int64, int64, error) {
+	c.calls++
+	return c.Sequencer.Next(ctx, n)
+}
+
+// encoderFunc 函数形式的短码编码
+type encoderFunc func(id int64) (string, error)
+
+func (f encoderFunc) Encode(id int64) (string, error) {
+	return f(id)
+}
+
+type poolFillerEnv struct {
+	cfg    PoolFillerConfig
+	locker Locker
+	pc     cache.PoolCache
+	bf     cache.BFCache
+	seq    Sequencer
+	enc    Encoder
+	// 号段表
+	segments dao.SegmentInter
+}
+
+func newPoolFillerEnv(t *testing.T) *poolFillerEnv {
+	_, client := testutil.NewRedis(t)
+	bf, err := bloom.NewBitsetBloom(client, bloom.Config{ErrorRate: 0.001, Capacity: 10000})
+	require.NoError(t, err)
+
+	// 号段长度小于批次大小，一个批次可能只拿到号段剩余的部分ID
+	db := testutil.OpenSqlite(t)
+	require.NoError(t, db.AutoMigrate(&dao.Segment{}))
+	sd := dao.NewSegmentDao(db)
+	require.NoError(t, sd.Init(context.Background(), repository.PoolBizTag, 25))
+
+	enc, err := codec.New(0x5DEECE66D)
+	require.NoError(t, err)
+
+	cfg := DefaultPoolFillerConfig()
+	cfg.Threshold, cfg.BatchSize = 40, 10
+	return &poolFillerEnv{
+		cfg:      cfg,
+		locker:   NewRedisLocker(client),
+		pc:       base62.NewCacheBase62(client),
+		bf:       bf,
+		seq:      repository.NewSequenceRepository(sd, repository.PoolBizTag),
+		enc:      enc,
+		segments: sd,
+	}
+}
+
+func (e *poolFillerEnv) filler() *PoolFiller {
+	return NewPoolFiller(e.cfg, e.locker, e.pc, e.bf, e.seq, e.enc)
+}
+
+// count 短码池中的数量，计数不存在时为0
+func (e *poolFillerEnv) count(t *testing.T) int64 {
+	count, err := e.pc.Count(context.Background())
+	if errors.Is(err, redis.Nil) {
+		return 0
+	}
+	require.NoError(t, err)
+	return count
+}
+
+func TestPoolFiller_Run(t *testing.T) {
+	env := newPoolFillerEnv(t)
+	ctx := context.Background()
+
+	// 编码结果与已有的哈希短码或者自定义短码重复时跳过
+	taken, err := env.enc.Encode(3)
+	require.NoError(t, err)
+	require.NoError(t, env.bf.Add(ctx, cache.BFKey, taken))
+
+	require.NoError(t, env.filler().Run(ctx))
+	count := env.count(t)
+	assert.GreaterOrEqual(t, count, env.cfg.Threshold)
+
+	codes := make(map[string]bool)
+	for i := int64(0); i < count; i++ {
+		code, er := env.pc.GetShortCode(ctx)
+		require.NoError(t, er)
+		codes[code] = true
+	}
+	assert.Len(t, codes, int(count))
+	assert.False(t, codes[taken])
+
+	// 数量达到阈值时不补充
+	require.NoError(t, env.pc.BatchInsertShortCodes(ctx, []string{"manual-1", "manual-2"}))
+	env.cfg.Threshold = 2
+	require.NoError(t, env.filler().Run(ctx))
+	assert.Equal(t, int64(2), env.count(t))
+
+	// 实例重启后ID以数据库的号段为准，不会再次分配已经写入短码池的ID
+	env.seq = repository.NewSequenceRepository(env.segments, repository.PoolBizTag)
+	env.cfg.Threshold = 12
+	require.NoError(t, env.filler().Run(ctx))
+	for i := 0; i < 12; i++ {
+		code, er := env.pc.GetShortCode(ctx)
+		require.NoError(t, er)
+		assert.False(t, codes[code], code)
+	}
+}
+
+func TestPoolFiller_Locked(t *testing.T) {
+	env := newPoolFillerEnv(t)
+	ctx := context.Background()
+
+	// 其他实例持有锁时跳过
+	unlock, ok, err := env.locker.TryLock(ctx, env.cfg.LockKey, env.cfg.LockExpiration)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, env.filler().Run(ctx))
+	assert.Zero(t, env.count(t))
+
+	require.NoError(t, unlock(ctx))
+	require.NoError(t, env.filler().Run(ctx))
+	assert.GreaterOrEqual(t, env.count(t), env.cfg.Threshold)
+}
+
+func TestPoolFiller_EmptyBatches(t *testing.T) {
+	env := newPoolFillerEnv(t)
+	ctx := context.Background()
+
+	// 过滤器异常导致所有批次都被过滤时不能无限申请ID
+	require.NoError(t, env.bf.Add(ctx, cache.BFKey, "dup"))
+	env.enc = encoderFunc(func(int64) (string, error) {
+		return "dup", nil
+	})
+	seq := &countingSequencer{Sequencer: env.seq}
+	env.seq = seq
+	assert.Error(t, env.filler().Run(ctx))
+	assert.Equal(t, env.cfg.MaxEmptyBatches, seq.calls)
+	assert.Zero(t, env.count(t))
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"time"
+
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+)
+
+// RecycleJob 定时回收隔离期结束的已删除短码，先物理删除数据库中的软删除记录释放唯一索引，
+// 再将短码归还到短码池，任一步骤失败都会把短码重新放回隔离区等待下一次回收
+type RecycleJob struct {
+	// 短码隔离区
+	rc cache.RecycleCache
+	// 短码池
+	pc cache.PoolCache
+	// 分库分表的短码数据操作
+	repo repository.GeneratorRepository
+	// 执行间隔
+	interval time.Duration
+	// 单次从隔离区取出的短码数量
+	batchSize int64
+	// 日志
+	el *elog.Component
+}
+
+func NewRecycleJob(rc cache.RecycleCache, pc cache.PoolCache, repo repository.GeneratorRepository,
+	interval time.Duration, batchSize int64) *RecycleJob {
+	return &RecycleJob{
+		rc:        rc,
+		pc:        pc,
+		repo:      repo,
+		interval:  interval,
+		batchSize: batchSize,
+		el:        elog.DefaultLogger,
+	}
+}
+
+// Start 按照间隔循环执行回收，直到ctx被取消
+func (r *RecycleJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Run(ctx); err != nil {
+				r.el.Error("回收短码失败", elog.FieldErr(err))
+			}
+		}
+	}
+}
+
+// Run 执行一轮回收，直到隔离区中没有到期的短码
+func (r *RecycleJob) Run(ctx context.Context) error {
+	for {
+		now := time.Now().UnixMilli()
+		codes, err := r.rc.Release(ctx, now, r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(codes) == 0 {
+			return nil
+		}
+
+		if err = r.repo.Purge(ctx, codes); err != nil {
+			return r.requeue(ctx, now, codes, err)
+		}
+
+		if err = r.pc.BatchInsertShortCodes(ctx, codes); err != nil {
+			return r.requeue(ctx, now, codes, err)
+		}
+	}
+}
+
+// requeue 回收失败后将短码放回隔离区，返回原始的错误
+func (r *RecycleJob) requeue(ctx context.Context, releaseAt int64, codes []string, cause error) error {
+	if err := r.rc.Quarantine(ctx, releaseAt, codes...); err != nil {
+		r.el.Error("短码放回隔离区失败",
+			elog.FieldErr(err),
+			elog.Any("codes", codes))
+	}
+
+	return cause
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache/base62"
+	"github.com/TimeWtr/generator/repository/cache/recycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// failPool 写入总是失败的短码池
+type failPool struct {
+	cache.PoolCache
+}
+
+func (failPool) BatchInsertShortCodes(context.Context, []string) error {
+	return errors.New("mock pool error")
+}
+
+func TestRecycleJob_Run(t *testing.T) {
+	f, _ := testutil.NewShards(t, 2)
+	repo := repository.NewGeneratorRepository(f, testutil.Pusher)
+	mr, client := testutil.NewRedis(t)
+	rc := recycle.NewCacheRecycle(client)
+	ctx := context.Background()
+
+	require.NoError(t, repo.BatchInsert(ctx, []domain.URLData{
+		{ID: 1, Biz: "test", OriginURL: "https://example.com/1", ShortCode: "released"},
+		{ID: 2, Biz: "test", OriginURL: "https://example.com/2", ShortCode: "waiting"},
+	}))
+	for _, code := range []string{"released", "waiting"} {
+		d, err := repo.Shard("test", code)
+		require.NoError(t, err)
+		sc, err := d.GetURLByShortCode(ctx, code)
+		require.NoError(t, err)
+		require.NoError(t, d.Delete(ctx, sc.ID))
+	}
+
+	now := time.Now()
+	require.NoError(t, rc.Quarantine(ctx, now.Add(-time.Minute).UnixMilli(), "released"))
+	require.NoError(t, rc.Quarantine(ctx, now.Add(time.Hour).UnixMilli(), "waiting"))
+
+	require.NoError(t, NewRecycleJob(rc, base62.NewCacheBase62(client), repo, time.Minute, 10).Run(ctx))
+
+	pool, err := mr.List(cache.PoolKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"released"}, pool)
+
+	members, err := mr.ZMembers(cache.RecycleKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"waiting"}, members)
+
+	// 软删除的记录被物理删除后短码可以重新写入
+	assert.NoError(t, repo.Insert(ctx, domain.URLData{ID: 3, Biz: "test", OriginURL: "https://example.com/3", ShortCode: "released"}))
+	assert.Error(t, repo.Insert(ctx, domain.URLData{ID: 4, Biz: "test", OriginURL: "https://example.com/4", ShortCode: "waiting"}))
+}
+
+func TestRecycleJob_Requeue(t *testing.T) {
+	f, _ := testutil.NewShards(t, 1)
+	mr, client := testutil.NewRedis(t)
+	rc := recycle.NewCacheRecycle(client)
+	ctx := context.Background()
+
+	require.NoError(t, rc.Quarantine(ctx, time.Now().Add(-time.Minute).UnixMilli(), "code1", "code2"))
+
+	job := NewRecycleJob(rc, failPool{}, repository.NewGeneratorRepository(f, testutil.Pusher), time.Minute, 10)
+	assert.EqualError(t, job.Run(ctx), "mock pool error")
+
+	// 归还失败的短码放回隔离区等待下一次回收
+	members, err := mr.ZMembers(cache.RecycleKey)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"code1", "code2"}, members)
+}
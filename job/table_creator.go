@@ -0,0 +1,115 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"time"
+
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultCreateAhead 默认提前创建的分表周期数
+	DefaultCreateAhead = 2
+	// DefaultCreateInterval 默认的检查间隔
+	DefaultCreateInterval = 6 * time.Hour
+	// DefaultCreateLockKey 创建分表任务的分布式锁
+	DefaultCreateLockKey = "ShortCodeTableCreatorLock"
+	// DefaultCreateLockExpiration 默认的锁过期时间
+	DefaultCreateLockExpiration = 5 * time.Minute
+)
+
+// TableCreatorConfig 创建分表任务的配置
+type TableCreatorConfig struct {
+	// 提前创建的周期数，需要保证写入新周期之前分表已经存在
+	Ahead int
+	// 检查间隔，需要远小于一个分表周期
+	Interval time.Duration
+	// 分布式锁
+	LockKey string
+	// 分布式锁的过期时间
+	LockExpiration time.Duration
+}
+
+func DefaultTableCreatorConfig() TableCreatorConfig {
+	return TableCreatorConfig{
+		Ahead:          DefaultCreateAhead,
+		Interval:       DefaultCreateInterval,
+		LockKey:        DefaultCreateLockKey,
+		LockExpiration: DefaultCreateLockExpiration,
+	}
+}
+
+// TableCreator 按照时间分表时定时通过GORM迁移创建当前周期及之后的分表，启动时立即执行一次，
+// 多个实例通过分布式锁保证同时只有一个实例在建表
+type TableCreator struct {
+	cfg TableCreatorConfig
+	// 按照时间分片的分库分表
+	f data_source.TimeFactory
+	// 分表对应的模型
+	model any
+	// 分布式锁
+	locker Locker
+	// 日志
+	el *elog.Component
+}
+
+func NewTableCreator(cfg TableCreatorConfig, f data_source.TimeFactory, model any, locker Locker) *TableCreator {
+	return &TableCreator{
+		cfg:    cfg,
+		f:      f,
+		model:  model,
+		locker: locker,
+		el:     elog.DefaultLogger,
+	}
+}
+
+// Start 立即创建一次分表，之后按照间隔循环检查，直到ctx被取消
+func (c *TableCreator) Start(ctx context.Context) {
+	if err := c.Run(ctx); err != nil {
+		c.el.Error("创建分表失败", elog.FieldErr(err))
+	}
+
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Run(ctx); err != nil {
+				c.el.Error("创建分表失败", elog.FieldErr(err))
+			}
+		}
+	}
+}
+
+// Run 执行一次建表，锁被其他实例持有时跳过
+func (c *TableCreator) Run(ctx context.Context) error {
+	unlock, ok, err := c.locker.TryLock(ctx, c.cfg.LockKey, c.cfg.LockExpiration)
+	if err != nil || !ok {
+		return err
+	}
+	defer func() {
+		if er := unlock(ctx); er != nil {
+			c.el.Error("释放创建分表的锁失败", elog.FieldErr(er))
+		}
+	}()
+
+	return c.f.Migrate(ctx, c.model, time.Now(), c.cfg.Ahead)
+}
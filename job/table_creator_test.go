@@ -0,0 +1,74 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestTableCreator_Run(t *testing.T) {
+	type record struct {
+		ID   int64
+		Name string
+	}
+
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/record.db"),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	now := time.Now()
+	f := data_source.NewTimeDataSource([]data_source.TimeDataSource{
+		{DS: data_source.DataSource{DB: db, TableCount: 6}},
+	}, "record_", now.AddDate(0, -1, 0))
+	table := func(months int) string {
+		y, m, _ := now.Date()
+		return "record_" + time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, now.Location()).Format("200601")
+	}
+
+	cfg := DefaultTableCreatorConfig()
+	locker := newLocker(t)
+	c := NewTableCreator(cfg, f, &record{}, locker)
+	ctx := context.Background()
+
+	// 其他实例持有锁时跳过
+	unlock, ok, err := locker.TryLock(ctx, cfg.LockKey, cfg.LockExpiration)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, c.Run(ctx))
+	assert.False(t, db.Migrator().HasTable(table(0)))
+	require.NoError(t, unlock(ctx))
+
+	// 创建当前周期及之后Ahead个周期的分表，之前的周期不创建
+	require.NoError(t, c.Run(ctx))
+	for i := 0; i <= cfg.Ahead; i++ {
+		assert.True(t, db.Migrator().HasTable(table(i)), table(i))
+	}
+	assert.False(t, db.Migrator().HasTable(table(-1)))
+	assert.False(t, db.Migrator().HasTable(table(cfg.Ahead+1)))
+
+	// 执行完成后释放锁
+	_, ok, err = locker.TryLock(ctx, cfg.LockKey, cfg.LockExpiration)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"errors"
+	"time"
+
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultBackfillBatchSize 默认每批次回填的记录数量
+	DefaultBackfillBatchSize = 500
+	// DefaultBackfillLockKey 回填任务分布式锁的前缀，每个分表单独加锁
+	DefaultBackfillLockKey = "ShortCodeURLHashBackfillLock"
+	// DefaultBackfillLockExpiration 默认的锁过期时间，需要大于回填一个分表的时间
+	DefaultBackfillLockExpiration = 30 * time.Minute
+)
+
+// URLHashBackfillConfig url_hash回填任务的配置
+type URLHashBackfillConfig struct {
+	// 每批次回填的数量
+	BatchSize int
+	// 分布式锁的前缀
+	LockKey string
+	// 分布式锁的过期时间
+	LockExpiration time.Duration
+}
+
+func DefaultURLHashBackfillConfig() URLHashBackfillConfig {
+	return URLHashBackfillConfig{
+		BatchSize:      DefaultBackfillBatchSize,
+		LockKey:        DefaultBackfillLockKey,
+		LockExpiration: DefaultBackfillLockExpiration,
+	}
+}
+
+// URLHashBackfill url_hash列上线之前写入的记录该列为空，按照原始URL去重和查询时都通过url_hash索引过滤，
+// 这些记录查询不到。回填任务按分表加锁，按照主键分批为url_hash为空的记录计算原始URL的SHA256，
+// 回填完成后再次执行只会扫描索引，可以在每次启动时执行
+type URLHashBackfill struct {
+	cfg URLHashBackfillConfig
+	// 分库分表
+	f data_source.Factory
+	// 分布式锁
+	locker Locker
+	// 日志
+	el *elog.Component
+}
+
+func NewURLHashBackfill(cfg URLHashBackfillConfig, f data_source.Factory, locker Locker) *URLHashBackfill {
+	return &URLHashBackfill{
+		cfg:    cfg,
+		f:      f,
+		locker: locker,
+		el:     elog.DefaultLogger,
+	}
+}
+
+// Run 回填所有分表，单个分表失败不影响其他分表，返回所有分表的错误
+func (b *URLHashBackfill) Run(ctx context.Context) error {
+	var errs []error
+	for _, dst := range b.f.AllDst() {
+		if err := b.backfillTable(ctx, dst); err != nil {
+			errs = append(errs, err)
+			b.el.Error("回填分表的url_hash失败",
+				elog.FieldErr(err),
+				elog.String("table", dst.Table))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// backfillTable 回填一个分表，其他实例正在回填时直接返回
+func (b *URLHashBackfill) backfillTable(ctx context.Context, dst data_source.Dst) error {
+	unlock, ok, err := b.locker.TryLock(ctx, b.cfg.LockKey+":"+dst.Table, b.cfg.LockExpiration)
+	if err != nil || !ok {
+		return err
+	}
+	defer func() {
+		if er := unlock(context.Background()); er != nil {
+			b.el.Error("释放url_hash回填任务的锁失败", elog.FieldErr(er))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, b.cfg.LockExpiration)
+	defer cancel()
+
+	d := dao.NewShardShortCodeDao(dst.DB, dst.Table)
+	var lastID int64
+	for {
+		rows, er := d.ListMissingURLHash(ctx, lastID, b.cfg.BatchSize)
+		if er != nil {
+			return er
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+
+		er = dst.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			td := dao.NewShardShortCodeDao(tx, dst.Table)
+			for _, row := range rows {
+				if e := td.FillURLHash(ctx, row.ID, domain.HashURL(row.OriginalURL)); e != nil {
+					return e
+				}
+			}
+			return nil
+		})
+		if er != nil {
+			return er
+		}
+
+		if len(rows) < b.cfg.BatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestURLHashBackfill_Run(t *testing.T) {
+	f, dbs := testutil.NewShards(t, 2)
+	repo := repository.NewGeneratorRepository(f, testutil.Pusher)
+	ctx := context.Background()
+
+	var data []domain.URLData
+	for i := 1; i <= 7; i++ {
+		data = append(data, domain.URLData{
+			ID:        int64(i),
+			Biz:       "test",
+			OriginURL: "https://example.com/old",
+			ShortCode: fmt.Sprintf("code%d", i),
+		})
+	}
+	data = append(data, domain.URLData{ID: 8, Biz: "test", OriginURL: "https://example.com/new", ShortCode: "code8"})
+	require.NoError(t, repo.BatchInsert(ctx, data))
+
+	// 模拟url_hash列上线之前写入的记录
+	for i, db := range dbs {
+		require.NoError(t, db.Table(fmt.Sprintf("short_code_%d", i)).
+			Where("original_url = ?", "https://example.com/old").
+			Update("url_hash", "").Error)
+	}
+
+	lookup := func(url string) int {
+		var n int
+		for _, d := range repo.Shards() {
+			scs, err := d.GetByOriginalURL(ctx, "", url)
+			require.NoError(t, err)
+			n += len(scs)
+		}
+		return n
+	}
+	assert.Zero(t, lookup("https://example.com/old"))
+
+	cfg := DefaultURLHashBackfillConfig()
+	cfg.BatchSize = 2
+	require.NoError(t, NewURLHashBackfill(cfg, f, newLocker(t)).Run(ctx))
+	assert.Equal(t, 7, lookup("https://example.com/old"))
+	assert.Equal(t, 1, lookup("https://example.com/new"))
+
+	for i, db := range dbs {
+		d := dao.NewShardShortCodeDao(db, fmt.Sprintf("short_code_%d", i))
+		rows, err := d.ListMissingURLHash(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, rows)
+	}
+}
@@ -18,7 +18,7 @@ import (
 	_ "embed"
 	"errors"
 
-	"github.com/TimeWtr/shortlink-platform/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/net/context"
 )
@@ -36,7 +36,7 @@ type CacheBase62 struct {
 	client redis.Cmdable
 }
 
-func NewCacheBase62(client redis.Cmdable) cache.CInter {
+func NewCacheBase62(client redis.Cmdable) cache.PoolCache {
 	return &CacheBase62{client: client}
 }
 
@@ -45,13 +45,17 @@ func (c *CacheBase62) Count(ctx context.Context) (int64, error) {
 }
 
 func (c *CacheBase62) GetShortCode(ctx context.Context) (string, error) {
-	val, err := c.client.Eval(ctx, getShortCodeScript,
-		[]string{cache.PoolKey, cache.PoolLengthKey}).Result()
+	code, err := c.client.Eval(ctx, getShortCodeScript,
+		[]string{cache.PoolKey, cache.PoolLengthKey}).Text()
 	if err != nil {
 		return "", err
 	}
 
-	return val.(string), nil
+	if code == "" {
+		return "", errors.New("short code not found")
+	}
+
+	return code, nil
 }
 
 func (c *CacheBase62) InsertShortCode(ctx context.Context, code string) error {
@@ -68,7 +72,11 @@ func (c *CacheBase62) InsertShortCode(ctx context.Context, code string) error {
 }
 
 func (c *CacheBase62) BatchInsertShortCodes(ctx context.Context, codes []string) error {
-	res, err := c.client.Eval(ctx, setShortCodeArrayScript, []string{cache.PoolKey, cache.PoolLengthKey}, codes, len(codes)).Int()
+	if len(codes) == 0 {
+		return nil
+	}
+
+	res, err := c.client.Eval(ctx, setShortCodeArrayScript, []string{cache.PoolKey, cache.PoolLengthKey}, codes).Int()
 	if err != nil {
 		return err
 	}
@@ -6,13 +6,13 @@ local poolKey = KEYS[1]
 local countKey = KEYS[2]
 
 local function isEmpty(s) 
-	return s == "" or s == nil
+	return s == "" or s == nil or s == false
 end
 
-lcoal val = redis.call("RPOP", poolKey)
+local val = redis.call("RPOP", poolKey)
 if isEmpty(val) then
+	return ""
+else
 	redis.call("INCRBY", countKey, -1)
 	return val
-else
-	return ""
-end
\ No newline at end of file
+end
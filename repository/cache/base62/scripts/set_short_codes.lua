@@ -4,10 +4,10 @@
 
 local poolKey = KEYS[1]
 local countKey = KEYS[2]
-local codes = ARGV[1]
-local codesCount = tonumber(ARGV[2])
+-- 每个参数都是一条短码
+local codesCount = #ARGV
 
-local res = redis.call("LPUSH", poolKey, unpack(codes))
+local res = redis.call("LPUSH", poolKey, unpack(ARGV))
 if res > 0 then
 	redis.call("INCRBY", countKey, codesCount)
 	return 0
@@ -0,0 +1,161 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+// Redis字符串最大512MB，位图最多只能使用2^32个位，误判率为0.1%时容量最多约为2.98亿，
+// 超过上限时需要使用RedisBloom模块或者可扩展的过滤器
+const maxBits = 1 << 32
+
+var ErrBitsetTooLarge = errors.New("过滤器需要的位图超过了Redis位图2^32位的上限")
+
+// BitsetBloom 在没有RedisBloom模块的环境下使用SETBIT/GETBIT实现的过滤器，
+// 位图大小和哈希函数个数按照与BF.RESERVE相同的误判率和容量计算
+type BitsetBloom struct {
+	client redis.Cmdable
+	// 位图的位数
+	bits uint64
+	// 哈希函数的个数
+	hashes uint64
+}
+
+// NewBitsetBloom 误判率和容量需要的位图超过上限时返回ErrBitsetTooLarge，不会截断位图导致实际误判率高于配置
+func NewBitsetBloom(client redis.Cmdable, cfg Config) (cache.BFCache, error) {
+	bits, hashes, err := optimal(cfg.ErrorRate, cfg.Capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BitsetBloom{
+		client: client,
+		bits:   bits,
+		hashes: hashes,
+	}, nil
+}
+
+// optimal 根据误判率p和容量n计算位图大小m = -n*ln(p)/(ln2)^2，哈希函数个数k = m/n*ln2
+func optimal(errorRate float64, capacity int64) (uint64, uint64, error) {
+	n := float64(capacity)
+	m := math.Ceil(-n * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	if m > maxBits {
+		return 0, 0, fmt.Errorf("%w: 误判率%g、容量%d需要%.0f位", ErrBitsetTooLarge, errorRate, capacity, m)
+	}
+
+	m = math.Max(1, m)
+	k := math.Round(m / n * math.Ln2)
+	k = math.Max(1, k)
+
+	return uint64(m), uint64(k), nil
+}
+
+// locations 使用双重哈希h1+i*h2模拟k个独立的哈希函数，计算数据在位图中的位置
+func (b *BitsetBloom) locations(data any) []int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(fmt.Sprint(data)))
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+
+	locs := make([]int64, b.hashes)
+	for i := uint64(0); i < b.hashes; i++ {
+		locs[i] = int64((h1 + i*h2) % b.bits)
+	}
+
+	return locs
+}
+
+// Reserve 设置位图的最后一位来预先分配整个位图，避免写入时频繁扩容
+func (b *BitsetBloom) Reserve(ctx context.Context, key string) error {
+	n, err := b.client.Exists(ctx, key).Result()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	return b.client.SetBit(ctx, key, int64(b.bits-1), 0).Err()
+}
+
+func (b *BitsetBloom) Add(ctx context.Context, key string, data any) error {
+	return b.MAdd(ctx, key, []any{data})
+}
+
+func (b *BitsetBloom) MAdd(ctx context.Context, key string, data []any) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := b.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, d := range data {
+			for _, loc := range b.locations(d) {
+				p.SetBit(ctx, key, loc, 1)
+			}
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Exists 判断短码是否存在于短码过滤器(cache.BFKey)中，这里的key是需要判断的短码
+func (b *BitsetBloom) Exists(ctx context.Context, key string) (bool, error) {
+	res, err := b.MExists(ctx, cache.BFKey, []any{key})
+	if err != nil {
+		return false, err
+	}
+
+	return res[key], nil
+}
+
+// MExists 批量判断数据是否存在于过滤器中，数据对应的k个位全部为1时才可能存在
+func (b *BitsetBloom) MExists(ctx context.Context, key string, data []any) (map[string]bool, error) {
+	res := make(map[string]bool, len(data))
+	if len(data) == 0 {
+		return res, nil
+	}
+
+	cmds := make([][]*redis.IntCmd, len(data))
+	_, err := b.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, d := range data {
+			for _, loc := range b.locations(d) {
+				cmds[i] = append(cmds[i], p.GetBit(ctx, key, loc))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, d := range data {
+		exists := true
+		for _, cmd := range cmds[i] {
+			if cmd.Val() == 0 {
+				exists = false
+				break
+			}
+		}
+		res[fmt.Sprint(d)] = exists
+	}
+
+	return res, nil
+}
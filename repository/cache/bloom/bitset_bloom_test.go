@@ -0,0 +1,77 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimal(t *testing.T) {
+	testCases := []struct {
+		name       string
+		errorRate  float64
+		capacity   int64
+		wantBits   uint64
+		wantHashes uint64
+		wantErr    error
+	}{
+		{
+			name:       "0.1% error rate",
+			errorRate:  0.001,
+			capacity:   1_000_000,
+			wantBits:   14377588,
+			wantHashes: 10,
+		},
+		{
+			name:       "1% error rate",
+			errorRate:  0.01,
+			capacity:   1_000_000,
+			wantBits:   9585059,
+			wantHashes: 7,
+		},
+		{
+			name:      "exceeds redis bitmap size",
+			errorRate: 0.0001,
+			capacity:  1_000_000_000,
+			wantErr:   ErrBitsetTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bits, hashes, err := optimal(tc.errorRate, tc.capacity)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantBits, bits)
+			assert.Equal(t, tc.wantHashes, hashes)
+		})
+	}
+}
+
+func TestBitsetBloom_Locations(t *testing.T) {
+	bf, err := NewBitsetBloom(nil, DefaultConfig())
+	require.NoError(t, err)
+	b := bf.(*BitsetBloom)
+	locs := b.locations("abc123")
+	assert.Len(t, locs, int(b.hashes))
+	for _, loc := range locs {
+		assert.True(t, loc >= 0 && uint64(loc) < b.bits)
+	}
+	// 相同的数据在所有实例上必须落到相同的位置
+	assert.Equal(t, locs, b.locations("abc123"))
+	assert.NotEqual(t, locs, b.locations("abc124"))
+}
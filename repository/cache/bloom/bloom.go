@@ -0,0 +1,109 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultErrorRate 过滤器默认的误判率
+	DefaultErrorRate = 0.001
+	// DefaultCapacity 过滤器默认的预期容量
+	DefaultCapacity = 10_000_000
+)
+
+// Config 过滤器的配置，误判率和容量共同决定了过滤器的大小和哈希函数的个数，
+// 所有实例必须使用相同的配置，否则位图实现计算出的位置不一致
+type Config struct {
+	// 误判率
+	ErrorRate float64
+	// 预期容量，超过容量后误判率会升高
+	Capacity int64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ErrorRate: DefaultErrorRate,
+		Capacity:  DefaultCapacity,
+	}
+}
+
+// New 根据Redis是否加载了RedisBloom模块自动选择过滤器的实现，
+// 加载了bf模块时使用BF.*命令，否则使用SETBIT/GETBIT实现的位图过滤器
+func New(ctx context.Context, client redis.Cmdable, cfg Config) (cache.BFCache, error) {
+	ok, err := HasBloomModule(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return NewRedisBloom(client, cfg), nil
+	}
+
+	return NewBitsetBloom(client, cfg)
+}
+
+// HasBloomModule 通过MODULE LIST查询Redis是否加载了RedisBloom模块，
+// 不支持MODULE命令的服务端(比如miniredis)视为没有加载
+func HasBloomModule(ctx context.Context, client redis.Cmdable) (bool, error) {
+	d, ok := client.(interface {
+		Do(ctx context.Context, args ...any) *redis.Cmd
+	})
+	if !ok {
+		return false, nil
+	}
+
+	modules, err := d.Do(ctx, "MODULE", "LIST").Slice()
+	if err != nil {
+		var re redis.Error
+		if errors.As(err, &re) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, module := range modules {
+		if strings.EqualFold(moduleName(module), "bf") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// moduleName 解析MODULE LIST返回的模块名，RESP2返回键值交替的数组，RESP3返回map
+func moduleName(module any) string {
+	switch m := module.(type) {
+	case []any:
+		for i := 0; i+1 < len(m); i += 2 {
+			if fmt.Sprint(m[i]) == "name" {
+				return fmt.Sprint(m[i+1])
+			}
+		}
+	case map[any]any:
+		if name, ok := m["name"]; ok {
+			return fmt.Sprint(name)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+// RedisBloom 基于RedisBloom模块BF.*命令实现的过滤器
+type RedisBloom struct {
+	client redis.Cmdable
+	cfg    Config
+}
+
+func NewRedisBloom(client redis.Cmdable, cfg Config) cache.BFCache {
+	return &RedisBloom{
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// Reserve 按照配置的误判率和容量创建过滤器，过滤器已经存在时直接返回
+func (r *RedisBloom) Reserve(ctx context.Context, key string) error {
+	err := r.client.BFReserve(ctx, key, r.cfg.ErrorRate, r.cfg.Capacity).Err()
+	if err != nil && strings.Contains(err.Error(), "item exists") {
+		return nil
+	}
+
+	return err
+}
+
+func (r *RedisBloom) Add(ctx context.Context, key string, data any) error {
+	return r.client.BFAdd(ctx, key, data).Err()
+}
+
+func (r *RedisBloom) MAdd(ctx context.Context, key string, data []any) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return r.client.BFMAdd(ctx, key, data...).Err()
+}
+
+// Exists 判断短码是否存在于短码过滤器(cache.BFKey)中，这里的key是需要判断的短码
+func (r *RedisBloom) Exists(ctx context.Context, key string) (bool, error) {
+	return r.client.BFExists(ctx, cache.BFKey, key).Result()
+}
+
+// MExists 批量判断数据是否存在于过滤器中，返回数据到是否存在的映射
+func (r *RedisBloom) MExists(ctx context.Context, key string, data []any) (map[string]bool, error) {
+	res := make(map[string]bool, len(data))
+	if len(data) == 0 {
+		return res, nil
+	}
+
+	exists, err := r.client.BFMExists(ctx, key, data...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, d := range data {
+		res[fmt.Sprint(d)] = exists[i]
+	}
+
+	return res, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// fakeBloomModule 在客户端拦截BF.*和MODULE LIST命令，模拟加载了RedisBloom模块的服务端，
+// 其他命令仍然发送到miniredis
+type fakeBloomModule struct {
+	mu       sync.Mutex
+	filters  map[string]map[string]bool
+	reserved [][]any
+}
+
+func newFakeBloomModule() *fakeBloomModule {
+	return &fakeBloomModule{filters: make(map[string]map[string]bool)}
+}
+
+func (f *fakeBloomModule) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (f *fakeBloomModule) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (f *fakeBloomModule) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		args := cmd.Args()
+		switch strings.ToLower(cmd.Name()) {
+		case "module":
+			cmd.(*redis.Cmd).SetVal([]any{[]any{"name", "bf", "ver", int64(20612)}})
+		case "bf.reserve":
+			key := fmt.Sprint(args[1])
+			if _, ok := f.filters[key]; ok {
+				cmd.SetErr(errors.New("ERR item exists"))
+				return cmd.Err()
+			}
+			f.filters[key] = make(map[string]bool)
+			f.reserved = append(f.reserved, args[2:])
+			cmd.(*redis.StatusCmd).SetVal("OK")
+		case "bf.add":
+			cmd.(*redis.BoolCmd).SetVal(f.add(fmt.Sprint(args[1]), args[2]))
+		case "bf.madd":
+			res := make([]bool, 0, len(args)-2)
+			for _, arg := range args[2:] {
+				res = append(res, f.add(fmt.Sprint(args[1]), arg))
+			}
+			cmd.(*redis.BoolSliceCmd).SetVal(res)
+		case "bf.exists":
+			cmd.(*redis.BoolCmd).SetVal(f.filters[fmt.Sprint(args[1])][fmt.Sprint(args[2])])
+		case "bf.mexists":
+			res := make([]bool, 0, len(args)-2)
+			for _, arg := range args[2:] {
+				res = append(res, f.filters[fmt.Sprint(args[1])][fmt.Sprint(arg)])
+			}
+			cmd.(*redis.BoolSliceCmd).SetVal(res)
+		default:
+			return next(ctx, cmd)
+		}
+
+		return nil
+	}
+}
+
+// add 与BF.ADD一致，过滤器不存在时自动创建，返回数据是否是新加入的
+func (f *fakeBloomModule) add(key string, data any) bool {
+	if f.filters[key] == nil {
+		f.filters[key] = make(map[string]bool)
+	}
+
+	d := fmt.Sprint(data)
+	added := !f.filters[key][d]
+	f.filters[key][d] = true
+	return added
+}
+
+func newRedisBloom(t *testing.T) (cache.BFCache, *fakeBloomModule) {
+	mr, _ := testutil.NewRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	module := newFakeBloomModule()
+	client.AddHook(module)
+
+	bf, err := New(context.Background(), client, Config{ErrorRate: 0.01, Capacity: 1000})
+	require.NoError(t, err)
+	return bf, module
+}
+
+func TestNew(t *testing.T) {
+	bf, _ := newRedisBloom(t)
+	assert.IsType(t, &RedisBloom{}, bf)
+
+	// miniredis不支持MODULE命令，使用位图过滤器
+	_, client := testutil.NewRedis(t)
+	bf, err := New(context.Background(), client, DefaultConfig())
+	require.NoError(t, err)
+	assert.IsType(t, &BitsetBloom{}, bf)
+}
+
+func TestRedisBloom_Reserve(t *testing.T) {
+	bf, module := newRedisBloom(t)
+	ctx := context.Background()
+
+	require.NoError(t, bf.Reserve(ctx, cache.BFKey))
+	// 过滤器已经存在时不返回错误，也不会重新创建
+	require.NoError(t, bf.Reserve(ctx, cache.BFKey))
+	assert.Equal(t, [][]any{{0.01, int64(1000)}}, module.reserved)
+}
+
+func TestRedisBloom(t *testing.T) {
+	bf, _ := newRedisBloom(t)
+	ctx := context.Background()
+	require.NoError(t, bf.Reserve(ctx, cache.BFKey))
+
+	require.NoError(t, bf.Add(ctx, cache.BFKey, "abc"))
+	require.NoError(t, bf.MAdd(ctx, cache.BFKey, []any{"def", "ghi"}))
+	require.NoError(t, bf.MAdd(ctx, cache.BFKey, nil))
+
+	// Exists的参数是需要判断的短码，固定查询短码过滤器
+	for code, want := range map[string]bool{"abc": true, "def": true, "xyz": false} {
+		exists, err := bf.Exists(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, want, exists, code)
+	}
+
+	res, err := bf.MExists(ctx, cache.BFKey, []any{"abc", "ghi", "xyz"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"abc": true, "ghi": true, "xyz": false}, res)
+
+	res, err = bf.MExists(ctx, cache.BFKey, nil)
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
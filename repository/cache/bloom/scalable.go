@@ -0,0 +1,399 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+//go:embed scripts/grow.lua
+var growScript string
+
+//go:embed scripts/swap.lua
+var swapScript string
+
+const (
+	// DefaultGrowth 每个新的子过滤器相对上一层的容量倍数
+	DefaultGrowth = 2
+	// DefaultTightening 每个新的子过滤器相对上一层的误判率比例，保证整体误判率收敛于ErrorRate/(1-Tightening)
+	DefaultTightening = 0.5
+	// DefaultRetireGrace 重建切换后旧的一代保留的时间
+	DefaultRetireGrace = time.Minute
+)
+
+// ScalableConfig 可扩展过滤器的配置，Config是第一层子过滤器的误判率和容量
+type ScalableConfig struct {
+	Config
+	// 容量增长倍数
+	Growth int64
+	// 误判率收紧比例
+	Tightening float64
+	// 重建切换后旧的一代保留的时间，切换前已经读取到旧的一代的查询在保留期内仍然可以正常判断
+	RetireGrace time.Duration
+}
+
+func DefaultScalableConfig() ScalableConfig {
+	return ScalableConfig{
+		Config:      DefaultConfig(),
+		Growth:      DefaultGrowth,
+		Tightening:  DefaultTightening,
+		RetireGrace: DefaultRetireGrace,
+	}
+}
+
+// Scalable 由多层子过滤器堆叠而成的可扩展过滤器，最新一层写满后自动创建容量更大、误判率更低的下一层，
+// 查询时依次判断所有子过滤器。过滤器按照代(generation)组织，重建时写入新的一代，完成后原子切换:
+//
+//	{key}:meta              当前代gen和正在重建的代building
+//	{key}:{gen}:meta        该代的子过滤器层数layers和最新一层已写入的数量count
+//	{key}:{gen}:{layer}     子过滤器
+type Scalable struct {
+	client redis.Cmdable
+	cfg    ScalableConfig
+	// 根据配置创建子过滤器，可以是RedisBloom或者位图实现
+	newFilter func(cfg Config) cache.BFCache
+	mu        sync.Mutex
+	// 每一层的子过滤器，同一层在不同代之间的配置相同，可以复用
+	layers []cache.BFCache
+}
+
+func NewScalable(client redis.Cmdable, cfg ScalableConfig, newFilter func(cfg Config) cache.BFCache) *Scalable {
+	return &Scalable{
+		client:    client,
+		cfg:       cfg,
+		newFilter: newFilter,
+	}
+}
+
+// layerConfig 计算第i层子过滤器的容量和误判率
+func (s *Scalable) layerConfig(i int) Config {
+	return Config{
+		ErrorRate: s.cfg.ErrorRate * math.Pow(s.cfg.Tightening, float64(i)),
+		Capacity:  s.cfg.Capacity * int64(math.Pow(float64(s.cfg.Growth), float64(i))),
+	}
+}
+
+func (s *Scalable) layer(i int) cache.BFCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.layers) <= i {
+		s.layers = append(s.layers, s.newFilter(s.layerConfig(len(s.layers))))
+	}
+
+	return s.layers[i]
+}
+
+func metaKey(key string) string {
+	return key + ":meta"
+}
+
+func genMetaKey(key string, gen int64) string {
+	return fmt.Sprintf("%s:%d:meta", key, gen)
+}
+
+func layerKey(key string, gen int64, layer int) string {
+	return fmt.Sprintf("%s:%d:%d", key, gen, layer)
+}
+
+// pointer 查询当前代和正在重建的代，building为0表示没有进行中的重建
+func (s *Scalable) pointer(ctx context.Context, key string) (gen int64, building int64, err error) {
+	vals, err := s.client.HMGet(ctx, metaKey(key), "gen", "building").Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var res [2]int64
+	for i, val := range vals {
+		if val == nil {
+			continue
+		}
+		if _, err = fmt.Sscan(val.(string), &res[i]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return res[0], res[1], nil
+}
+
+// layerCount 查询某一代的子过滤器层数
+func (s *Scalable) layerCount(ctx context.Context, key string, gen int64) (int, error) {
+	n, err := s.client.HGet(ctx, genMetaKey(key, gen), "layers").Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return n, err
+}
+
+// initGen 创建某一代的第一层子过滤器
+func (s *Scalable) initGen(ctx context.Context, key string, gen int64) error {
+	if err := s.layer(0).Reserve(ctx, layerKey(key, gen, 0)); err != nil {
+		return err
+	}
+
+	return s.client.HSetNX(ctx, genMetaKey(key, gen), "layers", 1).Err()
+}
+
+// Reserve 初始化过滤器，已经初始化过时直接返回
+func (s *Scalable) Reserve(ctx context.Context, key string) error {
+	gen, _, err := s.pointer(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err = s.client.HSetNX(ctx, metaKey(key), "gen", gen).Err(); err != nil {
+		return err
+	}
+
+	return s.initGen(ctx, key, gen)
+}
+
+func (s *Scalable) Add(ctx context.Context, key string, data any) error {
+	return s.MAdd(ctx, key, []any{data})
+}
+
+// MAdd 写入当前代，存在进行中的重建时同时写入重建的代，避免重建期间新增的数据在切换后丢失。
+// 写入后重新查询当前代和重建的代，写入期间开始了重建或者完成了切换时再写入新的代，直到没有遗漏的代
+func (s *Scalable) MAdd(ctx context.Context, key string, data []any) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	written := make(map[int64]bool, 2)
+	for {
+		gen, building, err := s.pointer(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		gens := []int64{gen}
+		if building > 0 {
+			gens = append(gens, building)
+		}
+
+		wrote := false
+		for _, g := range gens {
+			if written[g] {
+				continue
+			}
+
+			if err = s.add(ctx, key, g, data); err != nil {
+				return err
+			}
+			written[g], wrote = true, true
+		}
+
+		if !wrote {
+			return nil
+		}
+	}
+}
+
+// add 写入某一代的最新一层，写入后数量达到该层容量时创建下一层
+func (s *Scalable) add(ctx context.Context, key string, gen int64, data []any) error {
+	layers, err := s.layerCount(ctx, key, gen)
+	if err != nil {
+		return err
+	}
+
+	if layers == 0 {
+		if err = s.initGen(ctx, key, gen); err != nil {
+			return err
+		}
+		layers = 1
+	}
+
+	last := layers - 1
+	if err = s.layer(last).MAdd(ctx, layerKey(key, gen, last), data); err != nil {
+		return err
+	}
+
+	count, err := s.client.HIncrBy(ctx, genMetaKey(key, gen), "count", int64(len(data))).Result()
+	if err != nil {
+		return err
+	}
+
+	if count < s.layerConfig(last).Capacity {
+		return nil
+	}
+
+	// 先创建下一层再增加层数，避免查询和写入操作到还不存在的子过滤器，多个实例同时扩容时只有一个会成功
+	if err = s.layer(layers).Reserve(ctx, layerKey(key, gen, layers)); err != nil {
+		return err
+	}
+
+	return s.client.Eval(ctx, growScript, []string{genMetaKey(key, gen)}, layers).Err()
+}
+
+// Exists 判断短码是否存在于短码过滤器(cache.BFKey)中，这里的key是需要判断的短码
+func (s *Scalable) Exists(ctx context.Context, key string) (bool, error) {
+	res, err := s.MExists(ctx, cache.BFKey, []any{key})
+	if err != nil {
+		return false, err
+	}
+
+	return res[key], nil
+}
+
+// MExists 从最新一层开始依次判断，已经确认存在的数据不再查询更早的子过滤器
+func (s *Scalable) MExists(ctx context.Context, key string, data []any) (map[string]bool, error) {
+	res := make(map[string]bool, len(data))
+	for _, d := range data {
+		res[fmt.Sprint(d)] = false
+	}
+
+	if len(data) == 0 {
+		return res, nil
+	}
+
+	gen, _, err := s.pointer(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := s.layerCount(ctx, key, gen)
+	if err != nil {
+		return nil, err
+	}
+
+	remain := data
+	for i := layers - 1; i >= 0 && len(remain) > 0; i-- {
+		exists, er := s.layer(i).MExists(ctx, layerKey(key, gen, i), remain)
+		if er != nil {
+			return nil, er
+		}
+
+		next := remain[:0:0]
+		for _, d := range remain {
+			k := fmt.Sprint(d)
+			if exists[k] {
+				res[k] = true
+				continue
+			}
+			next = append(next, d)
+		}
+		remain = next
+	}
+
+	return res, nil
+}
+
+// Rebuild 将source中的全部数据写入新的一代过滤器，完成后原子切换为当前代，旧的一代在保留期结束后删除。
+// source按批次回调yield写入数据，重建期间新增的数据会同时写入新旧两代
+func (s *Scalable) Rebuild(ctx context.Context, key string, source func(yield func(data []any) error) error) error {
+	gen, building, err := s.pointer(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if building > 0 {
+		// 上一次重建中断遗留的数据
+		if err = s.drop(ctx, key, building); err != nil {
+			return err
+		}
+	}
+
+	newGen := gen + 1
+	if err = s.drop(ctx, key, newGen); err != nil {
+		return err
+	}
+
+	if err = s.initGen(ctx, key, newGen); err != nil {
+		return err
+	}
+
+	if err = s.client.HSet(ctx, metaKey(key), "building", newGen).Err(); err != nil {
+		return err
+	}
+
+	err = source(func(data []any) error {
+		return s.add(ctx, key, newGen, data)
+	})
+	if err != nil {
+		// 清除重建标记失败时保留重建的代，避免写入重新创建已经删除的代，由下一次重建清理
+		if er := s.client.HDel(ctx, metaKey(key), "building").Err(); er != nil {
+			return errors.Join(err, er)
+		}
+		return errors.Join(err, s.drop(ctx, key, newGen))
+	}
+
+	ok, err := s.client.Eval(ctx, swapScript, []string{metaKey(key)}, newGen).Bool()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("过滤器重建期间被其他任务抢占")
+	}
+
+	return s.retire(ctx, key, gen)
+}
+
+// retire 旧的一代在保留期结束后过期，切换前读取到旧的一代的查询不会因为子过滤器被删除而误判为不存在
+func (s *Scalable) retire(ctx context.Context, key string, gen int64) error {
+	if s.cfg.RetireGrace <= 0 {
+		return s.drop(ctx, key, gen)
+	}
+
+	keys, err := s.genKeys(ctx, key, gen)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, k := range keys {
+			p.Expire(ctx, k, s.cfg.RetireGrace)
+		}
+		return nil
+	})
+	return err
+}
+
+// drop 删除某一代的所有子过滤器和元数据
+func (s *Scalable) drop(ctx context.Context, key string, gen int64) error {
+	keys, err := s.genKeys(ctx, key, gen)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// genKeys 某一代的所有子过滤器和元数据的key
+func (s *Scalable) genKeys(ctx context.Context, key string, gen int64) ([]string, error) {
+	layers, err := s.layerCount(ctx, key, gen)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{genMetaKey(key, gen)}
+	for i := 0; i < layers; i++ {
+		keys = append(keys, layerKey(key, gen, i))
+	}
+
+	// 层数是先创建子过滤器再增加的，扩容中断时可能多出一层
+	keys = append(keys, layerKey(key, gen, layers))
+	return keys, nil
+}
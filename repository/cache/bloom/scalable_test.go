@@ -0,0 +1,197 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// hookFilter 写入子过滤器后执行hook，用于模拟写入期间其他实例的操作
+type hookFilter struct {
+	cache.BFCache
+	hook func()
+}
+
+func (h *hookFilter) MAdd(ctx context.Context, key string, data []any) error {
+	if err := h.BFCache.MAdd(ctx, key, data); err != nil {
+		return err
+	}
+
+	if hook := h.hook; hook != nil {
+		h.hook = nil
+		hook()
+	}
+	return nil
+}
+
+func newTestScalable(t *testing.T) (*miniredis.Miniredis, *Scalable, *hookFilter) {
+	mr, client := testutil.NewRedis(t)
+	cfg := DefaultScalableConfig()
+	cfg.Capacity = 10
+	hf := &hookFilter{}
+	s := NewScalable(client, cfg, func(cfg Config) cache.BFCache {
+		bf, err := NewBitsetBloom(client, cfg)
+		require.NoError(t, err)
+		// 只观察第一层的写入
+		if hf.BFCache == nil {
+			hf.BFCache = bf
+			return hf
+		}
+		return bf
+	})
+	require.NoError(t, s.Reserve(context.Background(), cache.BFKey))
+	return mr, s, hf
+}
+
+func codes(prefix string, n int) []any {
+	res := make([]any, n)
+	for i := range res {
+		res[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return res
+}
+
+func TestScalable_Grow(t *testing.T) {
+	_, s, _ := newTestScalable(t)
+	ctx := context.Background()
+
+	data := codes("code", 35)
+	for i := 0; i < len(data); i += 5 {
+		require.NoError(t, s.MAdd(ctx, cache.BFKey, data[i:i+5]))
+	}
+
+	// 第一层容量10，第二层20，写满后继续创建第三层
+	layers, err := s.layerCount(ctx, cache.BFKey, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, layers)
+
+	res, err := s.MExists(ctx, cache.BFKey, data)
+	require.NoError(t, err)
+	for _, d := range data {
+		assert.True(t, res[d.(string)], d)
+	}
+
+	ok, err := s.Exists(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestScalable_Rebuild(t *testing.T) {
+	mr, s, _ := newTestScalable(t)
+	ctx := context.Background()
+	require.NoError(t, s.MAdd(ctx, cache.BFKey, []any{"stale", "kept"}))
+
+	err := s.Rebuild(ctx, cache.BFKey, func(yield func(data []any) error) error {
+		data := append(codes("code", 15), "kept")
+		for i := 0; i < len(data); i += 5 {
+			if err := yield(data[i:min(i+5, len(data))]); err != nil {
+				return err
+			}
+		}
+		// 重建期间新增的数据同时写入新旧两代
+		return s.Add(ctx, cache.BFKey, "during")
+	})
+	require.NoError(t, err)
+
+	gen, building, err := s.pointer(ctx, cache.BFKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), gen)
+	assert.Zero(t, building)
+
+	res, err := s.MExists(ctx, cache.BFKey, append(codes("code", 15), "kept", "during", "stale"))
+	require.NoError(t, err)
+	for k, ok := range res {
+		assert.Equal(t, k != "stale", ok, k)
+	}
+
+	// 旧的一代在保留期结束后删除
+	assert.True(t, mr.Exists(layerKey(cache.BFKey, 0, 0)))
+	assert.Equal(t, DefaultRetireGrace, mr.TTL(layerKey(cache.BFKey, 0, 0)))
+	mr.FastForward(DefaultRetireGrace)
+	assert.False(t, mr.Exists(layerKey(cache.BFKey, 0, 0)))
+	assert.False(t, mr.Exists(genMetaKey(cache.BFKey, 0)))
+}
+
+func TestScalable_RebuildFailed(t *testing.T) {
+	mr, s, _ := newTestScalable(t)
+	ctx := context.Background()
+	require.NoError(t, s.Add(ctx, cache.BFKey, "code"))
+
+	err := s.Rebuild(ctx, cache.BFKey, func(yield func(data []any) error) error {
+		if err := yield([]any{"other"}); err != nil {
+			return err
+		}
+		return errors.New("mock error")
+	})
+	assert.EqualError(t, err, "mock error")
+
+	// 重建失败时清除重建标记和重建的代，当前代不受影响
+	gen, building, err := s.pointer(ctx, cache.BFKey)
+	require.NoError(t, err)
+	assert.Zero(t, gen)
+	assert.Zero(t, building)
+	assert.False(t, mr.Exists(layerKey(cache.BFKey, 1, 0)))
+
+	ok, err := s.Exists(ctx, "code")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestScalable_AddDuringSwap(t *testing.T) {
+	_, s, hf := newTestScalable(t)
+	ctx := context.Background()
+
+	// 写入读取到的当前代之后、返回之前，其他实例完成了重建和切换
+	hf.hook = func() {
+		require.NoError(t, s.Rebuild(ctx, cache.BFKey, func(func(data []any) error) error {
+			return nil
+		}))
+	}
+	require.NoError(t, s.Add(ctx, cache.BFKey, "racing"))
+
+	gen, _, err := s.pointer(ctx, cache.BFKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), gen)
+
+	ok, err := s.Exists(ctx, "racing")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestScalable_MExistsRetiredGen(t *testing.T) {
+	_, s, _ := newTestScalable(t)
+	ctx := context.Background()
+	require.NoError(t, s.Add(ctx, cache.BFKey, "code"))
+
+	// 切换前已经读取到旧的一代的查询，保留期内仍然可以判断存在
+	layers, err := s.layerCount(ctx, cache.BFKey, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Rebuild(ctx, cache.BFKey, func(yield func(data []any) error) error {
+		return yield([]any{"code"})
+	}))
+
+	res, err := s.layer(layers-1).MExists(ctx, layerKey(cache.BFKey, 0, layers-1), []any{"code"})
+	require.NoError(t, err)
+	assert.True(t, res["code"])
+}
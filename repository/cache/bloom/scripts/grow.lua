@@ -0,0 +1,15 @@
+-- 子过滤器扩容
+-- 1. 层数与调用方看到的一致时才增加层数，多个实例同时扩容时只有一个会成功
+-- 2. 新的一层从0开始计数
+
+local genMetaKey = KEYS[1]
+local expected = tonumber(ARGV[1])
+
+local layers = tonumber(redis.call("HGET", genMetaKey, "layers"))
+if layers ~= expected then
+	return 0
+end
+
+redis.call("HINCRBY", genMetaKey, "layers", 1)
+redis.call("HSET", genMetaKey, "count", 0)
+return 1
@@ -0,0 +1,15 @@
+-- 重建完成后切换过滤器
+-- 1. 只有正在重建的代与调用方一致时才切换
+-- 2. 切换当前代并清除重建标记
+
+local metaKey = KEYS[1]
+local newGen = ARGV[1]
+
+local building = redis.call("HGET", metaKey, "building")
+if building ~= newGen then
+	return 0
+end
+
+redis.call("HSET", metaKey, "gen", newGen)
+redis.call("HDEL", metaKey, "building")
+return 1
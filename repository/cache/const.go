@@ -18,4 +18,9 @@ const (
 	PoolKey       = "ShortCodePool"
 	PoolLengthKey = "ShortCodePoolLength"
 	BFKey         = "ShortCodeBF"
+	RecycleKey    = "ShortCodeRecycle"
+	// MappingKeyPrefix 短码与原始URL映射的前缀
+	MappingKeyPrefix = "ShortCodeMapping:"
+	// IdempotencyKeyPrefix 客户端幂等键的前缀
+	IdempotencyKeyPrefix = "ShortCodeIdempotency:"
 )
@@ -33,46 +33,31 @@ var setShortCodeScript string
 //go:embed scripts/set_short_codes.lua
 var setShortCodeArrayScript string
 
+// CacheHash 哈希短码方案的缓存，短码池写入成功后同时写入过滤器，过滤器的实现由调用方根据
+// Redis是否加载了RedisBloom模块选择，参考bloom.New
 type CacheHash struct {
+	cache.BFCache
 	client redis.Cmdable
 }
 
-func NewCacheHash(client redis.Cmdable) cache.Cacher {
-	return &CacheHash{client: client}
-}
-
-func (c *CacheHash) Reserve(ctx context.Context, key string) error {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (c *CacheHash) Add(ctx context.Context, key string, data any) error {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (c *CacheHash) MAdd(ctx context.Context, key string, data []any) error {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (c *CacheHash) Exists(ctx context.Context, key string) (bool, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (c *CacheHash) MExists(ctx context.Context, key string, data []any) (map[string]bool, error) {
-	//TODO implement me
-	panic("implement me")
+func NewCacheHash(client redis.Cmdable, bf cache.BFCache) cache.Cacher {
+	return &CacheHash{
+		BFCache: bf,
+		client:  client,
+	}
 }
 
 func (c *CacheHash) Count(ctx context.Context) (int64, error) {
-	return c.client.Get(ctx, "shortCodeCount").Int64()
+	return c.client.Get(ctx, cache.PoolLengthKey).Int64()
 }
 
 // GetShortCode 查询短码数量、获取一条可用的预生成短码、更新短码数量
 func (c *CacheHash) GetShortCode(ctx context.Context) (string, error) {
-	code := c.client.Eval(ctx, getShortCodeScript, []string{cache.PoolKey, cache.PoolLengthKey}).String()
+	code, err := c.client.Eval(ctx, getShortCodeScript, []string{cache.PoolKey, cache.PoolLengthKey}).Text()
+	if err != nil {
+		return "", err
+	}
+
 	if code == "" {
 		return "", errors.New("short code not found")
 	}
@@ -82,7 +67,7 @@ func (c *CacheHash) GetShortCode(ctx context.Context) (string, error) {
 
 // InsertShortCode 新增一条新的预生成短码
 func (c *CacheHash) InsertShortCode(ctx context.Context, code string) error {
-	res, err := c.client.Eval(ctx, setShortCodeScript, []string{cache.PoolKey, cache.PoolLengthKey, cache.BFKey}, code).Int()
+	res, err := c.client.Eval(ctx, setShortCodeScript, []string{cache.PoolKey, cache.PoolLengthKey}, code).Int()
 	if err != nil {
 		return err
 	}
@@ -91,15 +76,19 @@ func (c *CacheHash) InsertShortCode(ctx context.Context, code string) error {
 		return errors.New("failed to insert single short code to cache")
 	}
 
-	return nil
+	return c.Add(ctx, cache.BFKey, code)
 }
 
 // BatchInsertShortCodes 预生成的短码批量写入缓存中
 func (c *CacheHash) BatchInsertShortCodes(ctx context.Context, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
 	res, err := c.client.Eval(ctx,
 		setShortCodeArrayScript,
-		[]string{cache.PoolKey, cache.PoolLengthKey, cache.BFKey},
-		codes, len(codes)).Int()
+		[]string{cache.PoolKey, cache.PoolLengthKey},
+		codes).Int()
 	if err != nil {
 		return err
 	}
@@ -108,5 +97,10 @@ func (c *CacheHash) BatchInsertShortCodes(ctx context.Context, codes []string) e
 		return errors.New("failed to insert batch short codes to cache")
 	}
 
-	return nil
+	data := make([]any, len(codes))
+	for i, code := range codes {
+		data[i] = code
+	}
+
+	return c.MAdd(ctx, cache.BFKey, data)
 }
@@ -0,0 +1,68 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idempotent
+
+import (
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+var (
+	//go:embed scripts/acquire.lua
+	acquireScript string
+	//go:embed scripts/abandon.lua
+	abandonScript string
+)
+
+type CacheIdempotency struct {
+	client redis.Cmdable
+}
+
+func NewCacheIdempotency(client redis.Cmdable) cache.IdempotencyCache {
+	return &CacheIdempotency{client: client}
+}
+
+// Acquire 通过Lua脚本占用幂等键，占用和查询结果是原子的
+func (c *CacheIdempotency) Acquire(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	res, err := c.client.Eval(ctx, acquireScript, []string{c.key(key)}, ttl.Milliseconds()).Slice()
+	if err != nil {
+		return "", false, err
+	}
+
+	if len(res) != 2 {
+		return "", false, errors.New("unexpected idempotency script result")
+	}
+
+	acquired, _ := res[0].(int64)
+	val, _ := res[1].(string)
+	return val, acquired == 1, nil
+}
+
+func (c *CacheIdempotency) Complete(ctx context.Context, key string, val string, ttl time.Duration) error {
+	return c.client.Set(ctx, c.key(key), val, ttl).Err()
+}
+
+func (c *CacheIdempotency) Abandon(ctx context.Context, key string) error {
+	return c.client.Eval(ctx, abandonScript, []string{c.key(key)}).Err()
+}
+
+func (c *CacheIdempotency) key(key string) string {
+	return cache.IdempotencyKeyPrefix + key
+}
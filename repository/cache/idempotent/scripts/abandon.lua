@@ -0,0 +1,9 @@
+-- 释放处理中的幂等键，已经保存结果的幂等键不能删除
+
+local key = KEYS[1]
+
+if redis.call("GET", key) == "" then
+	return redis.call("DEL", key)
+end
+
+return 0
@@ -0,0 +1,17 @@
+-- 占用幂等键
+-- 1. 幂等键不存在时写入空值表示请求处理中，返回占用成功
+-- 2. 幂等键已经存在时返回保存的结果，空值表示请求仍在处理中
+
+local key = KEYS[1]
+local ttl = tonumber(ARGV[1])
+
+if redis.call("SET", key, "", "NX", "PX", ttl) then
+	return {1, ""}
+end
+
+local val = redis.call("GET", key)
+if not val then
+	val = ""
+end
+
+return {0, val}
@@ -0,0 +1,72 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapping
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+type CacheMapping struct {
+	client redis.Cmdable
+}
+
+func NewCacheMapping(client redis.Cmdable) cache.MappingCache {
+	return &CacheMapping{client: client}
+}
+
+func (c *CacheMapping) Get(ctx context.Context, shortCode string) (cache.Mapping, error) {
+	val, err := c.client.Get(ctx, c.key(shortCode)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return cache.Mapping{}, cache.ErrMappingMiss
+		}
+		return cache.Mapping{}, err
+	}
+
+	var m cache.Mapping
+	return m, json.Unmarshal(val, &m)
+}
+
+func (c *CacheMapping) Set(ctx context.Context, shortCode string, m cache.Mapping, ttl time.Duration) error {
+	val, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Set(ctx, c.key(shortCode), val, ttl).Err()
+}
+
+func (c *CacheMapping) Del(ctx context.Context, shortCodes ...string) error {
+	if len(shortCodes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(shortCodes))
+	for i, code := range shortCodes {
+		keys[i] = c.key(code)
+	}
+
+	return c.client.Del(ctx, keys...).Err()
+}
+
+func (c *CacheMapping) key(shortCode string) string {
+	return cache.MappingKeyPrefix + shortCode
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recycle
+
+import (
+	_ "embed"
+
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+)
+
+//go:embed scripts/release.lua
+var releaseScript string
+
+type CacheRecycle struct {
+	client redis.Cmdable
+}
+
+func NewCacheRecycle(client redis.Cmdable) cache.RecycleCache {
+	return &CacheRecycle{client: client}
+}
+
+func (c *CacheRecycle) Quarantine(ctx context.Context, releaseAt int64, codes ...string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	members := make([]redis.Z, 0, len(codes))
+	for _, code := range codes {
+		members = append(members, redis.Z{
+			Score:  float64(releaseAt),
+			Member: code,
+		})
+	}
+
+	return c.client.ZAdd(ctx, cache.RecycleKey, members...).Err()
+}
+
+// Release 通过Lua脚本查询并删除到期的短码，多实例并发回收时不会重复取出
+func (c *CacheRecycle) Release(ctx context.Context, now int64, limit int64) ([]string, error) {
+	return c.client.Eval(ctx, releaseScript, []string{cache.RecycleKey}, now, limit).StringSlice()
+}
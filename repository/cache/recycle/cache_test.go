@@ -0,0 +1,74 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recycle
+
+import (
+	"testing"
+
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestCacheRecycle(t *testing.T) {
+	_, client := testutil.NewRedis(t)
+	rc := NewCacheRecycle(client)
+	ctx := context.Background()
+
+	require.NoError(t, rc.Quarantine(ctx, 100, "code1", "code2"))
+	require.NoError(t, rc.Quarantine(ctx, 200, "code3"))
+	require.NoError(t, rc.Quarantine(ctx, 300))
+
+	testCases := []struct {
+		name  string
+		now   int64
+		limit int64
+		want  []string
+	}{
+		{
+			name:  "quarantine not ended",
+			now:   99,
+			limit: 10,
+			want:  []string{},
+		},
+		{
+			name:  "limited",
+			now:   150,
+			limit: 1,
+			want:  []string{"code1"},
+		},
+		{
+			name:  "released once",
+			now:   250,
+			limit: 10,
+			want:  []string{"code2", "code3"},
+		},
+		{
+			name:  "empty",
+			now:   300,
+			limit: 10,
+			want:  []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			codes, err := rc.Release(ctx, tc.now, tc.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, codes)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+-- 取出隔离期已经结束的短码
+-- 1. 按照释放时间查询到期的短码
+-- 2. 从隔离区中删除，保证多实例下同一个短码只会被取出一次
+
+local recycleKey = KEYS[1]
+local now = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+local codes = redis.call("ZRANGEBYSCORE", recycleKey, "-inf", now, "LIMIT", 0, limit)
+if #codes > 0 then
+	redis.call("ZREM", recycleKey, unpack(codes))
+end
+
+return codes
@@ -14,7 +14,12 @@
 
 package cache
 
-import "golang.org/x/net/context"
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/net/context"
+)
 
 type Cacher interface {
 	PoolCache
@@ -44,3 +49,43 @@ type BFCache interface {
 	// MExists 判断一批数据是否存在
 	MExists(ctx context.Context, key string, data []any) (map[string]bool, error)
 }
+
+// ErrMappingMiss 短码映射的缓存不存在
+var ErrMappingMiss = errors.New("短码映射缓存不存在")
+
+// Mapping 短码映射的缓存内容，OriginalURL为空表示短码不存在，用于防止缓存穿透
+type Mapping struct {
+	ID          int64  `json:"id"`
+	Biz         string `json:"biz"`
+	OriginalURL string `json:"original_url"`
+	ExpireAt    int64  `json:"expire_at"`
+}
+
+// MappingCache 短码与原始URL的映射缓存
+type MappingCache interface {
+	// Get 查询短码的映射，缓存不存在时返回ErrMappingMiss
+	Get(ctx context.Context, shortCode string) (Mapping, error)
+	// Set 缓存短码的映射
+	Set(ctx context.Context, shortCode string, m Mapping, ttl time.Duration) error
+	// Del 删除短码的映射
+	Del(ctx context.Context, shortCodes ...string) error
+}
+
+// IdempotencyCache 客户端幂等键的存储，同一个幂等键在保留期内只会生成一次短链
+type IdempotencyCache interface {
+	// Acquire 占用幂等键，占用成功时返回true，幂等键已经存在时返回false和保存的结果，结果为空表示请求仍在处理中
+	Acquire(ctx context.Context, key string, ttl time.Duration) (string, bool, error)
+	// Complete 保存幂等键对应的生成结果
+	Complete(ctx context.Context, key string, val string, ttl time.Duration) error
+	// Abandon 生成失败时释放处理中的幂等键，客户端可以使用相同的幂等键重试
+	Abandon(ctx context.Context, key string) error
+}
+
+// RecycleCache 已删除短码的隔离区，短码只有在隔离期结束后才能归还到短码池，
+// 避免删除后短时间内被重新分配，导致旧链接跳转到新的URL
+type RecycleCache interface {
+	// Quarantine 将短码放入隔离区，releaseAt(毫秒时间戳)之后可以被回收
+	Quarantine(ctx context.Context, releaseAt int64, codes ...string) error
+	// Release 取出最多limit条隔离期已经结束的短码，取出的短码会从隔离区中移除
+	Release(ctx context.Context, now int64, limit int64) ([]string, error)
+}
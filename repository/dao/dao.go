@@ -16,81 +16,316 @@ package dao
 
 import (
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 
-	"github.com/TimeWtr/shortlink-platform/generator/domain"
+	"github.com/TimeWtr/generator/domain"
 	"golang.org/x/net/context"
 )
 
 type ShortCodeInter interface {
 	Insert(ctx context.Context, data domain.URLData) error
+	// BatchInsert 批量插入短码记录，短码重复时返回gorm.ErrDuplicatedKey
+	BatchInsert(ctx context.Context, data []domain.URLData) error
+	// Update 修改未删除的短链，记录不存在或者已经被删除时返回gorm.ErrRecordNotFound
 	Update(ctx context.Context, data domain.URLData) error
 	GetURLByID(ctx context.Context, id int64) (ShortCode, error)
 	GetURLByShortCode(ctx context.Context, shortCode string) (ShortCode, error)
+	// FindReusable 查询同一业务下同一创建者为原始URL生成的未过期短链，用于幂等生成
+	FindReusable(ctx context.Context, biz string, creator string, originURL string, now int64) (ShortCode, error)
+	// GetByOriginalURL 查询指向原始URL的所有未删除短链，biz为空时查询所有业务
+	GetByOriginalURL(ctx context.Context, biz string, originURL string) ([]ShortCode, error)
+	// ClearExpiration 清除过期时间，短链永不过期
+	ClearExpiration(ctx context.Context, id int64) error
+	// Delete 软删除，只标记删除时间，短码在清理前仍然占用唯一索引
 	Delete(ctx context.Context, id int64) error
+	// ListExpired 按照主键顺序查询id大于afterID、在now之前已经过期的未删除短链，最多limit条
+	ListExpired(ctx context.Context, now int64, afterID int64, limit int) ([]ShortCode, error)
+	// DeleteExpired 软删除ids中在now之前已经过期的短链，返回实际删除的记录，需要在事务中调用
+	DeleteExpired(ctx context.Context, ids []int64, now int64) ([]ShortCode, error)
+	// Purge 物理删除已经软删除的短码记录，回收短码前需要先清理
+	Purge(ctx context.Context, shortCodes []string) error
+	// Scan 按照主键顺序查询id大于afterID的记录，包含已经软删除的记录，最多limit条，用于数据迁移
+	Scan(ctx context.Context, afterID int64, limit int) ([]ShortCode, error)
+	// Upsert 按照原始记录写入，主键或短码已经存在时覆盖，用于数据迁移
+	Upsert(ctx context.Context, rows []ShortCode) error
+	// MaxID 查询最大的主键，包含已经软删除的记录，没有记录时返回0，用于数据迁移
+	MaxID(ctx context.Context) (int64, error)
+	// Remove 按照主键物理删除记录，不区分是否已经软删除，用于数据迁移
+	Remove(ctx context.Context, ids []int64) error
+	// ListMissingURLHash 按照主键顺序查询id大于afterID、还没有计算url_hash的记录，最多limit条
+	ListMissingURLHash(ctx context.Context, afterID int64, limit int) ([]ShortCode, error)
+	// FillURLHash 为还没有计算url_hash的记录回填原始URL的哈希
+	FillURLHash(ctx context.Context, id int64, urlHash string) error
 }
 
 type ShortCodeDao struct {
 	db *gorm.DB
+	// 分表名，为空时使用默认的表名
+	table string
 }
 
 func NewShortCodeDao(db *gorm.DB) ShortCodeInter {
 	return &ShortCodeDao{db: db}
 }
 
+// NewShardShortCodeDao 操作指定分表的短码数据
+func NewShardShortCodeDao(db *gorm.DB, table string) ShortCodeInter {
+	return &ShortCodeDao{db: db, table: table}
+}
+
+func (d *ShortCodeDao) query(ctx context.Context) *gorm.DB {
+	tx := d.db.WithContext(ctx).Model(&ShortCode{})
+	if d.table != "" {
+		tx = tx.Table(d.table)
+	}
+
+	return tx
+}
+
+// Insert 插入一条短码记录，分库分表时ID需要由调用方指定全局唯一的分布式ID，短码重复时返回gorm.ErrDuplicatedKey
 func (d *ShortCodeDao) Insert(ctx context.Context, data domain.URLData) error {
-	now := time.Now().Unix()
-	return d.db.WithContext(ctx).Create(&ShortCode{
+	sc := toEntity(data, time.Now().UnixMilli())
+	return d.translate(d.query(ctx).Create(&sc).Error)
+}
+
+func (d *ShortCodeDao) BatchInsert(ctx context.Context, data []domain.URLData) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	now := time.Now().UnixMilli()
+	scs := make([]ShortCode, len(data))
+	for i, item := range data {
+		scs[i] = toEntity(item, now)
+	}
+
+	return d.translate(d.query(ctx).Create(&scs).Error)
+}
+
+// translate 将驱动的唯一索引冲突等错误转换为GORM的通用错误，不依赖打开链接时是否开启了TranslateError
+func (d *ShortCodeDao) translate(err error) error {
+	if err == nil || d.db.Config == nil {
+		return err
+	}
+
+	if t, ok := d.db.Dialector.(gorm.ErrorTranslator); ok {
+		return t.Translate(err)
+	}
+
+	return err
+}
+
+func toEntity(data domain.URLData, now int64) ShortCode {
+	return ShortCode{
+		ID:          data.ID,
+		Biz:         data.Biz,
 		OriginalURL: data.OriginURL,
+		URLHash:     domain.HashURL(data.OriginURL),
 		ShortCode:   data.ShortCode,
 		ExpireAt:    data.ExpireAt,
 		Creator:     data.Creator,
 		Comment:     data.Comment,
+		Custom:      data.Custom,
 		CreateTime:  now,
 		UpdateTime:  now,
-	}).Error
+	}
 }
 
+// Update 根据ID修改短链的原始URL、过期时间和备注，零值字段保持不变，短码和创建者不允许修改
 func (d *ShortCodeDao) Update(ctx context.Context, data domain.URLData) error {
-	return d.db.WithContext(ctx).
-		Model(&ShortCode{}).
-		Where("id = ?", data.ID).
-		Updates(map[string]interface{}{
-			"short_code":  data.ShortCode,
-			"expire_at":   data.ExpireAt,
-			"comment":     data.Comment,
-			"creator":     data.Creator,
-			"update_time": time.Now(),
+	sc := ShortCode{
+		OriginalURL: data.OriginURL,
+		ExpireAt:    data.ExpireAt,
+		Comment:     data.Comment,
+		UpdateTime:  time.Now().UnixMilli(),
+	}
+	if data.OriginURL != "" {
+		sc.URLHash = domain.HashURL(data.OriginURL)
+	}
+
+	// 每次修改都会更新update_time，没有影响任何行说明记录已经被删除
+	res := d.query(ctx).
+		Where("id = ? AND delete_time = 0", data.ID).
+		Updates(sc)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (d *ShortCodeDao) ClearExpiration(ctx context.Context, id int64) error {
+	return d.query(ctx).
+		Where("id = ? AND delete_time = 0", id).
+		Updates(map[string]any{
+			"expire_at":   0,
+			"update_time": time.Now().UnixMilli(),
 		}).Error
 }
 
 func (d *ShortCodeDao) GetURLByID(ctx context.Context, id int64) (ShortCode, error) {
 	var res ShortCode
-	return res, d.db.WithContext(ctx).
-		Model(&ShortCode{}).
-		Where("id = ?", id).
+	return res, d.query(ctx).
+		Where("id = ? AND delete_time = 0", id).
 		First(&res).Error
 }
 
 func (d *ShortCodeDao) GetURLByShortCode(ctx context.Context, shortCode string) (ShortCode, error) {
 	var res ShortCode
-	return res, d.db.WithContext(ctx).
-		Model(&ShortCode{}).
-		Where("short_code = ?", shortCode).
+	return res, d.query(ctx).
+		Where("short_code = ? AND delete_time = 0", shortCode).
 		First(&res).Error
 }
 
+// FindReusable 先通过url_hash索引缩小范围，再比较原始URL排除哈希冲突，多条时返回最新的一条
+func (d *ShortCodeDao) FindReusable(ctx context.Context, biz string, creator string, originURL string, now int64) (ShortCode, error) {
+	var res ShortCode
+	return res, d.query(ctx).
+		Where("url_hash = ? AND biz = ? AND creator = ? AND original_url = ?",
+			domain.HashURL(originURL), biz, creator, originURL).
+		Where("delete_time = 0 AND (expire_at = 0 OR expire_at > ?)", now).
+		Order("id DESC").
+		First(&res).Error
+}
+
+func (d *ShortCodeDao) GetByOriginalURL(ctx context.Context, biz string, originURL string) ([]ShortCode, error) {
+	tx := d.query(ctx).
+		Where("url_hash = ? AND original_url = ? AND delete_time = 0", domain.HashURL(originURL), originURL)
+	if biz != "" {
+		tx = tx.Where("biz = ?", biz)
+	}
+
+	var res []ShortCode
+	return res, tx.Order("id").Find(&res).Error
+}
+
 func (d *ShortCodeDao) Delete(ctx context.Context, id int64) error {
-	return d.db.WithContext(ctx).Delete(&ShortCode{}, id).Error
+	now := time.Now().UnixMilli()
+	return d.query(ctx).
+		Where("id = ? AND delete_time = 0", id).
+		Updates(map[string]any{
+			"delete_time": now,
+			"update_time": now,
+		}).Error
+}
+
+func (d *ShortCodeDao) ListExpired(ctx context.Context, now int64, afterID int64, limit int) ([]ShortCode, error) {
+	var res []ShortCode
+	return res, d.query(ctx).
+		Where("id > ? AND delete_time = 0 AND expire_at > 0 AND expire_at < ?", afterID, now).
+		Order("id").
+		Limit(limit).
+		Find(&res).Error
+}
+
+// DeleteExpired 加锁后重新确认过期状态，扫描之后被修改了过期时间的短链不会被删除
+func (d *ShortCodeDao) DeleteExpired(ctx context.Context, ids []int64, now int64) ([]ShortCode, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var res []ShortCode
+	err := d.query(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND delete_time = 0 AND expire_at > 0 AND expire_at < ?", ids, now).
+		Find(&res).Error
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+
+	expired := make([]int64, len(res))
+	for i, sc := range res {
+		expired[i] = sc.ID
+	}
+
+	deleteTime := time.Now().UnixMilli()
+	return res, d.query(ctx).
+		Where("id IN ?", expired).
+		Updates(map[string]any{
+			"delete_time": deleteTime,
+			"update_time": deleteTime,
+		}).Error
+}
+
+func (d *ShortCodeDao) Purge(ctx context.Context, shortCodes []string) error {
+	if len(shortCodes) == 0 {
+		return nil
+	}
+
+	return d.query(ctx).
+		Where("short_code IN ? AND delete_time > 0", shortCodes).
+		Delete(&ShortCode{}).Error
+}
+
+func (d *ShortCodeDao) Scan(ctx context.Context, afterID int64, limit int) ([]ShortCode, error) {
+	var res []ShortCode
+	return res, d.query(ctx).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&res).Error
+}
+
+func (d *ShortCodeDao) Upsert(ctx context.Context, rows []ShortCode) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return d.query(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&rows).Error
+}
+
+func (d *ShortCodeDao) MaxID(ctx context.Context) (int64, error) {
+	var res int64
+	return res, d.query(ctx).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&res).Error
+}
+
+func (d *ShortCodeDao) Remove(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return d.query(ctx).
+		Where("id IN ?", ids).
+		Delete(&ShortCode{}).Error
+}
+
+// ListMissingURLHash 包含已经软删除的记录，url_hash列上线之前写入的记录该列为空
+func (d *ShortCodeDao) ListMissingURLHash(ctx context.Context, afterID int64, limit int) ([]ShortCode, error) {
+	var res []ShortCode
+	return res, d.query(ctx).
+		Where("id > ? AND url_hash = ''", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&res).Error
+}
+
+// FillURLHash 只更新仍然为空的url_hash，回填期间被修改了原始URL的记录已经写入了新的哈希，不会被覆盖
+func (d *ShortCodeDao) FillURLHash(ctx context.Context, id int64, urlHash string) error {
+	return d.query(ctx).
+		Where("id = ? AND url_hash = ''", id).
+		Update("url_hash", urlHash).Error
 }
 
 type ShortCode struct {
 	ID          int64  `gorm:"column:id;type:bigint;autoIncrement;not null;primaryKey;comment:主键" json:"id"`
+	Biz         string `gorm:"column:biz;type:varchar(64);not null;default:'';comment:所属业务" json:"biz"`
 	OriginalURL string `gorm:"column:original_url;type:text;not null;comment:原始URL" json:"original_url"`
+	URLHash     string `gorm:"column:url_hash;type:char(64);index:url_hash_idx;not null;default:'';comment:原始URL的SHA256" json:"url_hash"`
 	ShortCode   string `gorm:"column:short_code;type:varchar(255);uniqueIndex:short_code_idx;not null;comment:短码" json:"short_code"`
-	ExpireAt    int64  `gorm:"column:expire_at;type:bigint;not null;comment:过期时间" json:"expire_at"`
+	ExpireAt    int64  `gorm:"column:expire_at;type:bigint;not null;comment:过期时间，0表示永不过期" json:"expire_at"`
 	Comment     string `gorm:"column:comment;type:text;not null;comment:备注" json:"comment"`
 	Creator     string `gorm:"column:creator;type:varchar(255);not null;comment:创建者" json:"creator"`
+	Custom      bool   `gorm:"column:custom;type:tinyint(1);not null;default:0;comment:是否是自定义短码" json:"custom"`
 	CreateTime  int64  `gorm:"column:create_time;type:bigint;not null;comment:创建时间" json:"create_time"`
 	UpdateTime  int64  `gorm:"column:update_time;type:bigint;not null; comment:更新时间" json:"update_time"`
+	DeleteTime  int64  `gorm:"column:delete_time;type:bigint;not null;default:0;comment:删除时间，0表示未删除" json:"delete_time"`
 }
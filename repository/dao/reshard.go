@@ -0,0 +1,96 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReshardInter interface {
+	// GetPhase 查询迁移任务当前的阶段，任务不存在时返回空字符串
+	GetPhase(ctx context.Context, task string) (string, error)
+	// SetPhase 更新迁移任务的阶段
+	SetPhase(ctx context.Context, task string, phase string) error
+	// GetCheckpoints 查询迁移任务所有源分表的迁移进度
+	GetCheckpoints(ctx context.Context, task string) ([]ReshardCheckpoint, error)
+	// SaveCheckpoint 保存源分表的迁移进度
+	SaveCheckpoint(ctx context.Context, cp ReshardCheckpoint) error
+}
+
+type ReshardDao struct {
+	db *gorm.DB
+}
+
+func NewReshardDao(db *gorm.DB) ReshardInter {
+	return &ReshardDao{db: db}
+}
+
+func (d *ReshardDao) GetPhase(ctx context.Context, task string) (string, error) {
+	var res ReshardTask
+	err := d.db.WithContext(ctx).
+		Where("name = ?", task).
+		First(&res).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+
+	return res.Phase, err
+}
+
+func (d *ReshardDao) SetPhase(ctx context.Context, task string, phase string) error {
+	return d.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"phase", "update_time"})}).
+		Create(&ReshardTask{
+			Name:       task,
+			Phase:      phase,
+			UpdateTime: time.Now().UnixMilli(),
+		}).Error
+}
+
+func (d *ReshardDao) GetCheckpoints(ctx context.Context, task string) ([]ReshardCheckpoint, error) {
+	var res []ReshardCheckpoint
+	return res, d.db.WithContext(ctx).
+		Where("task = ?", task).
+		Find(&res).Error
+}
+
+func (d *ReshardDao) SaveCheckpoint(ctx context.Context, cp ReshardCheckpoint) error {
+	cp.UpdateTime = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&cp).Error
+}
+
+// ReshardTask 迁移任务
+type ReshardTask struct {
+	Name       string `gorm:"column:name;type:varchar(128);primaryKey;comment:迁移任务名称" json:"name"`
+	Phase      string `gorm:"column:phase;type:varchar(32);not null;comment:迁移阶段" json:"phase"`
+	UpdateTime int64  `gorm:"column:update_time;type:bigint;not null;comment:更新时间" json:"update_time"`
+}
+
+// ReshardCheckpoint 源分表的迁移进度
+type ReshardCheckpoint struct {
+	Task       string `gorm:"column:task;type:varchar(128);primaryKey;comment:迁移任务名称" json:"task"`
+	SrcTable   string `gorm:"column:src_table;type:varchar(128);primaryKey;comment:源分表" json:"src_table"`
+	LastID     int64  `gorm:"column:last_id;type:bigint;not null;default:0;comment:已经迁移的最大ID" json:"last_id"`
+	Copied     int64  `gorm:"column:copied;type:bigint;not null;default:0;comment:已经迁移的记录数" json:"copied"`
+	Done       bool   `gorm:"column:done;type:tinyint(1);not null;default:0;comment:是否迁移完成" json:"done"`
+	UpdateTime int64  `gorm:"column:update_time;type:bigint;not null;comment:更新时间" json:"update_time"`
+}
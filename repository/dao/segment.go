@@ -0,0 +1,98 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SegmentRetryCounts 乐观锁冲突时的最大重试次数
+const SegmentRetryCounts = 5
+
+var ErrSegmentConflict = errors.New("号段申请冲突次数过多")
+
+type SegmentInter interface {
+	// Init 初始化业务的号段记录，记录已经存在时不做修改
+	Init(ctx context.Context, bizTag string, step int64) error
+	// Allocate 申请一个新的号段，返回[start, end)，号段在返回前已经持久化
+	Allocate(ctx context.Context, bizTag string) (start int64, end int64, err error)
+}
+
+type SegmentDao struct {
+	db *gorm.DB
+}
+
+func NewSegmentDao(db *gorm.DB) SegmentInter {
+	return &SegmentDao{db: db}
+}
+
+func (d *SegmentDao) Init(ctx context.Context, bizTag string, step int64) error {
+	now := time.Now().UnixMilli()
+	return d.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Segment{
+			BizTag:     bizTag,
+			Step:       step,
+			CreateTime: now,
+			UpdateTime: now,
+		}).Error
+}
+
+// Allocate 通过版本号实现乐观锁，只有读取到的版本号没有被其他实例修改时才能更新成功，
+// 多个实例并发申请时每个号段只会分配给一个实例
+func (d *SegmentDao) Allocate(ctx context.Context, bizTag string) (int64, int64, error) {
+	for i := 0; i < SegmentRetryCounts; i++ {
+		var seg Segment
+		err := d.db.WithContext(ctx).
+			Where("biz_tag = ?", bizTag).
+			First(&seg).Error
+		if err != nil {
+			return 0, 0, err
+		}
+
+		res := d.db.WithContext(ctx).
+			Model(&Segment{}).
+			Where("biz_tag = ? AND version = ?", bizTag, seg.Version).
+			Updates(map[string]any{
+				"max_id":      gorm.Expr("max_id + step"),
+				"version":     gorm.Expr("version + 1"),
+				"update_time": time.Now().UnixMilli(),
+			})
+		if res.Error != nil {
+			return 0, 0, res.Error
+		}
+
+		if res.RowsAffected == 1 {
+			return seg.MaxID + 1, seg.MaxID + seg.Step + 1, nil
+		}
+	}
+
+	return 0, 0, ErrSegmentConflict
+}
+
+// Segment 号段表，每个业务一条记录，max_id是已经分配出去的最大ID
+type Segment struct {
+	BizTag     string `gorm:"column:biz_tag;type:varchar(128);primaryKey;comment:业务标识" json:"biz_tag"`
+	MaxID      int64  `gorm:"column:max_id;type:bigint;not null;default:0;comment:已分配的最大ID" json:"max_id"`
+	Step       int64  `gorm:"column:step;type:bigint;not null;comment:号段长度" json:"step"`
+	Version    int64  `gorm:"column:version;type:bigint;not null;default:0;comment:乐观锁版本号" json:"version"`
+	CreateTime int64  `gorm:"column:create_time;type:bigint;not null;comment:创建时间" json:"create_time"`
+	UpdateTime int64  `gorm:"column:update_time;type:bigint;not null;comment:更新时间" json:"update_time"`
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func newSegmentDao(t *testing.T, step int64) dao.SegmentInter {
+	db := testutil.OpenSqlite(t)
+	require.NoError(t, db.AutoMigrate(&dao.Segment{}))
+	d := dao.NewSegmentDao(db)
+	require.NoError(t, d.Init(context.Background(), "test", step))
+	return d
+}
+
+func TestSegmentDao_Init(t *testing.T) {
+	d := newSegmentDao(t, 10)
+	ctx := context.Background()
+
+	start, end, err := d.Allocate(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, [2]int64{1, 11}, [2]int64{start, end})
+
+	// 记录已经存在时不修改号段长度和已分配的最大ID
+	require.NoError(t, d.Init(ctx, "test", 100))
+	start, end, err = d.Allocate(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, [2]int64{11, 21}, [2]int64{start, end})
+
+	_, _, err = d.Allocate(ctx, "unknown")
+	assert.Error(t, err)
+}
+
+func TestSegmentDao_AllocateConcurrent(t *testing.T) {
+	const (
+		step       = 10
+		allocators = 8
+		perWorker  = 20
+	)
+	d := newSegmentDao(t, step)
+	ctx := context.Background()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		segments [][2]int64
+	)
+	for i := 0; i < allocators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < perWorker; {
+				start, end, err := d.Allocate(ctx, "test")
+				// 冲突次数过多时由调用方重试
+				if errors.Is(err, dao.ErrSegmentConflict) {
+					continue
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				mu.Lock()
+				segments = append(segments, [2]int64{start, end})
+				mu.Unlock()
+				n++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 每个号段只分配给一个申请方，所有号段首尾相接没有遗漏
+	require.Len(t, segments, allocators*perWorker)
+	slices.SortFunc(segments, func(a, b [2]int64) int {
+		return int(a[0] - b[0])
+	})
+	next := int64(1)
+	for _, seg := range segments {
+		assert.Equal(t, [2]int64{next, next + step}, seg)
+		next = seg[1]
+	}
+}
@@ -15,18 +15,261 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/TimeWtr/generator"
 	"github.com/TimeWtr/generator/data_source"
 	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/repository/dao"
+	lmt "github.com/TimeWtr/local_message_table"
 	"golang.org/x/net/context"
+	"gorm.io/gorm"
 )
 
+// GeneratorRepository 分库分表的短码数据操作，分片键由业务和短码计算，写入和按短码的查询都路由到短码所在的分表，
+// 不包含短码的查询需要扫描所有分表
 type GeneratorRepository interface {
 	// Insert 插入一条短码记录数据
-	Insert(ctx context.Context, data domain.URLData, shardingKey int)
-	// BatchInsert 批量插入同库同表记录数据
-	BatchInsert(ctx context.Context, data []domain.URLData, shardingKey int)
+	Insert(ctx context.Context, data domain.URLData) error
+	// BatchInsert 批量插入短码记录数据，按照分表分组后每个分表批量写入一次
+	BatchInsert(ctx context.Context, data []domain.URLData) error
+	// Shard 获取业务短码所在分表的数据库操作，业务未知时biz为空，按业务分库时路由到默认的分库分表
+	Shard(biz string, shortCode string) (dao.ShortCodeInter, error)
+	// ShardTx 获取业务短码所在分表在事务tx中的数据库操作，tx不是在短码所在的库上开启的事务时返回错误
+	ShardTx(tx *gorm.DB, biz string, shortCode string) (dao.ShortCodeInter, error)
+	// Exec 在业务短码所在分库的本地消息表事务中执行fn，短码数据和本地消息在同一个库的事务中写入
+	Exec(ctx context.Context, biz string, shortCode string, fn TxFunc) error
+	// Shards 获取所有分表的数据库操作
+	Shards() []dao.ShortCodeInter
+	// GetByID 在所有分表中并发查询ID对应的短链
+	GetByID(ctx context.Context, id int64) (dao.ShortCode, error)
+	// FindReusable 在所有分表中并发查询同一业务下同一创建者为原始URL生成的未过期短链
+	FindReusable(ctx context.Context, biz string, creator string, originURL string, now int64) (dao.ShortCode, error)
+	// Purge 物理删除已经软删除的短码记录，回收的短码不包含业务，需要在所有分表中删除
+	Purge(ctx context.Context, shortCodes []string) error
+}
+
+// MessagePusher 本地消息表的事务执行，lmt.MessagePusher实现了该接口
+type MessagePusher interface {
+	ExecTo(ctx context.Context, fn func(ctx context.Context, tx *gorm.DB) ([]lmt.Messages, error), shardingKey any) error
+}
+
+// PusherFunc 获取在分库db上开启事务的本地消息表，本地消息表需要与短码数据部署在同一个库中
+type PusherFunc func(db *gorm.DB) MessagePusher
+
+// TxFunc 本地消息表事务中执行的操作，d是绑定在事务上的短码分表操作，返回需要在同一个事务中写入的消息
+type TxFunc func(ctx context.Context, d dao.ShortCodeInter) ([]lmt.Messages, error)
+
+// KeyFunc 将业务和短码转换为Factory.GetDB使用的分片键
+type KeyFunc func(biz string, shortCode string) data_source.ShardingKey
+
+// HashKey 默认的分片键，短码的FNV哈希，适用于按照整数取模的分片算法
+func HashKey(_ string, shortCode string) data_source.ShardingKey {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(shortCode))
+	return data_source.Int64Key(h.Sum32())
+}
+
+// CodeKey 直接使用短码作为分片键，适用于一致性哈希等支持字符串分片键的分片算法
+func CodeKey(_ string, shortCode string) data_source.ShardingKey {
+	return data_source.StringKey(shortCode)
+}
+
+// BizKey 在fn的分片键外组合业务，配合data_source.NewBizRouter先按照业务再按照fn的分片键分片
+func BizKey(fn KeyFunc) KeyFunc {
+	return func(biz string, shortCode string) data_source.ShardingKey {
+		return data_source.BizKey{Biz: biz, Key: fn(biz, shortCode)}
+	}
+}
+
+type Option func(r *generatorRepositoryImpl)
+
+// WithKeyFunc 替换默认的分片键，需要与分片算法支持的分片键类型一致
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(r *generatorRepositoryImpl) {
+		r.key = fn
+	}
 }
 
 type generatorRepositoryImpl struct {
 	dataSource data_source.Factory
+	// 分库对应的本地消息表
+	pusher PusherFunc
+	key    KeyFunc
+}
+
+func NewGeneratorRepository(dataSource data_source.Factory, pusher PusherFunc, opts ...Option) GeneratorRepository {
+	r := &generatorRepositoryImpl{
+		dataSource: dataSource,
+		pusher:     pusher,
+		key:        HashKey,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (g *generatorRepositoryImpl) Insert(ctx context.Context, data domain.URLData) error {
+	d, err := g.Shard(data.Biz, data.ShortCode)
+	if err != nil {
+		return err
+	}
+
+	return d.Insert(ctx, data)
+}
+
+func (g *generatorRepositoryImpl) BatchInsert(ctx context.Context, data []domain.URLData) error {
+	groups := make(map[data_source.Dst][]domain.URLData)
+	for _, item := range data {
+		dst, err := g.dst(item.Biz, item.ShortCode)
+		if err != nil {
+			return err
+		}
+		groups[dst] = append(groups[dst], item)
+	}
+
+	for dst, items := range groups {
+		err := dao.NewShardShortCodeDao(dst.DB, dst.Table).BatchInsert(ctx, items)
+		if err != nil {
+			return fmt.Errorf("批量写入分表%s失败: %w", dst.Table, err)
+		}
+	}
+
+	return nil
+}
+
+func (g *generatorRepositoryImpl) Shard(biz string, shortCode string) (dao.ShortCodeInter, error) {
+	dst, err := g.dst(biz, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return dao.NewShardShortCodeDao(dst.DB, dst.Table), nil
+}
+
+func (g *generatorRepositoryImpl) ShardTx(tx *gorm.DB, biz string, shortCode string) (dao.ShortCodeInter, error) {
+	dst, err := g.dst(biz, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dst.Owns(tx) {
+		return nil, fmt.Errorf("%w: 事务不是在短码%s所在的分表%s的库上开启的", generator.ErrShardingFailed, shortCode, dst.Table)
+	}
+
+	return dao.NewShardShortCodeDao(tx, dst.Table), nil
+}
+
+// Exec 本地消息表的事务开启在短码所在的分库上，事务中再校验一次事务所在的库
+func (g *generatorRepositoryImpl) Exec(ctx context.Context, biz string, shortCode string, fn TxFunc) error {
+	dst, err := g.dst(biz, shortCode)
+	if err != nil {
+		return err
+	}
+
+	return g.pusher(dst.DB).ExecTo(ctx, func(ctx context.Context, tx *gorm.DB) ([]lmt.Messages, error) {
+		d, er := g.ShardTx(tx, biz, shortCode)
+		if er != nil {
+			return nil, er
+		}
+
+		return fn(ctx, d)
+	}, shortCode)
+}
+
+func (g *generatorRepositoryImpl) Shards() []dao.ShortCodeInter {
+	dsts := g.dataSource.AllDst()
+	res := make([]dao.ShortCodeInter, 0, len(dsts))
+	for _, dst := range dsts {
+		res = append(res, dao.NewShardShortCodeDao(dst.DB, dst.Table))
+	}
+
+	return res
+}
+
+// GetByID ID不是分片键，依次查询所有分表，直到找到为止
+func (g *generatorRepositoryImpl) GetByID(ctx context.Context, id int64) (dao.ShortCode, error) {
+	return g.first(ctx, func(ctx context.Context, d dao.ShortCodeInter) (dao.ShortCode, error) {
+		return d.GetURLByID(ctx, id)
+	})
+}
+
+func (g *generatorRepositoryImpl) FindReusable(ctx context.Context, biz string,
+	creator string, originURL string, now int64) (dao.ShortCode, error) {
+	return g.first(ctx, func(ctx context.Context, d dao.ShortCodeInter) (dao.ShortCode, error) {
+		return d.FindReusable(ctx, biz, creator, originURL, now)
+	})
+}
+
+func (g *generatorRepositoryImpl) Purge(ctx context.Context, shortCodes []string) error {
+	if len(shortCodes) == 0 {
+		return nil
+	}
+
+	for _, dst := range g.dataSource.AllDst() {
+		if err := dao.NewShardShortCodeDao(dst.DB, dst.Table).Purge(ctx, shortCodes); err != nil {
+			return fmt.Errorf("清理分表%s失败: %w", dst.Table, err)
+		}
+	}
+
+	return nil
+}
+
+// first 并发在所有分表中执行查询，任一分表查询到记录后取消其他分表的查询。
+// 所有分表都不存在时返回gorm.ErrRecordNotFound，没有查询到记录且有分表查询失败时返回第一个错误
+func (g *generatorRepositoryImpl) first(ctx context.Context,
+	fn func(ctx context.Context, d dao.ShortCodeInter) (dao.ShortCode, error)) (dao.ShortCode, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		found    bool
+		res      dao.ShortCode
+		firstErr error
+	)
+	for _, d := range g.Shards() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sc, err := fn(ctx, d)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case found:
+			case err == nil:
+				found, res = true, sc
+				cancel()
+			case !errors.Is(err, gorm.ErrRecordNotFound) && firstErr == nil:
+				firstErr = err
+			}
+		}()
+	}
+
+	wg.Wait()
+	switch {
+	case found:
+		return res, nil
+	case firstErr != nil:
+		return dao.ShortCode{}, firstErr
+	default:
+		return dao.ShortCode{}, gorm.ErrRecordNotFound
+	}
+}
+
+func (g *generatorRepositoryImpl) dst(biz string, shortCode string) (data_source.Dst, error) {
+	dst, err := g.dataSource.GetDB(g.key(biz, shortCode))
+	if err != nil {
+		return data_source.Dst{}, fmt.Errorf("短码%s分片失败: %w", shortCode, err)
+	}
+
+	return dst, nil
 }
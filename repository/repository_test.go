@@ -0,0 +1,93 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/TimeWtr/generator"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/dao"
+	lmt "github.com/TimeWtr/local_message_table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+func TestGeneratorRepository_Exec(t *testing.T) {
+	f, _ := testutil.NewShards(t, 3)
+	repo := repository.NewGeneratorRepository(f, testutil.Pusher)
+	ctx := context.Background()
+
+	for i := 1; i <= 20; i++ {
+		data := domain.URLData{
+			ID:        int64(i),
+			Biz:       "test",
+			OriginURL: fmt.Sprintf("https://example.com/%d", i),
+			ShortCode: fmt.Sprintf("code%d", i),
+		}
+		err := repo.Exec(ctx, data.Biz, data.ShortCode, func(ctx context.Context, d dao.ShortCodeInter) ([]lmt.Messages, error) {
+			return nil, d.Insert(ctx, data)
+		})
+		require.NoError(t, err)
+	}
+
+	sc, err := repo.GetByID(ctx, 13)
+	require.NoError(t, err)
+	assert.Equal(t, "code13", sc.ShortCode)
+
+	d, err := repo.Shard("test", "code13")
+	require.NoError(t, err)
+	_, err = d.GetURLByShortCode(ctx, "code13")
+	assert.NoError(t, err)
+
+	_, err = repo.GetByID(ctx, 100)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestGeneratorRepository_ShardTxMismatch(t *testing.T) {
+	f, dbs := testutil.NewShards(t, 2)
+	// 本地消息表总是在第一个库上开启事务
+	repo := repository.NewGeneratorRepository(f, func(*gorm.DB) repository.MessagePusher {
+		return testutil.TxPusher{DB: dbs[0]}
+	})
+
+	var codes [2]string
+	for i := 0; codes[0] == "" || codes[1] == ""; i++ {
+		code := fmt.Sprintf("code%d", i)
+		dst, err := f.GetDB(repository.HashKey("", code))
+		require.NoError(t, err)
+		if dst.DB == dbs[0] {
+			codes[0] = code
+		} else {
+			codes[1] = code
+		}
+	}
+
+	err := repo.Exec(context.Background(), "", codes[0], func(ctx context.Context, d dao.ShortCodeInter) ([]lmt.Messages, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = repo.Exec(context.Background(), "", codes[1], func(ctx context.Context, d dao.ShortCodeInter) ([]lmt.Messages, error) {
+		return nil, errors.New("不应该执行")
+	})
+	assert.ErrorIs(t, err, generator.ErrShardingFailed)
+}
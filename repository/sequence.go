@@ -0,0 +1,66 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"sync"
+
+	"github.com/TimeWtr/generator/repository/dao"
+	"golang.org/x/net/context"
+)
+
+// PoolBizTag 短码池预生成使用的号段业务标识
+const PoolBizTag = "short_code_pool"
+
+type SequenceRepository interface {
+	// Next 申请最多n个连续且已经持久化的递增ID，返回[start, end)
+	Next(ctx context.Context, n int64) (start int64, end int64, err error)
+}
+
+// sequenceRepositoryImpl 在本地缓存一个号段，号段用完后再到数据库申请新的号段，
+// 实例重启时本地未使用的ID会被丢弃，ID不连续但不会重复
+type sequenceRepositoryImpl struct {
+	d      dao.SegmentInter
+	bizTag string
+	mu     sync.Mutex
+	// 当前号段中下一个可用的ID
+	cur int64
+	// 当前号段的结束位置，不包含
+	end int64
+}
+
+func NewSequenceRepository(d dao.SegmentInter, bizTag string) SequenceRepository {
+	return &sequenceRepositoryImpl{
+		d:      d,
+		bizTag: bizTag,
+	}
+}
+
+func (s *sequenceRepositoryImpl) Next(ctx context.Context, n int64) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cur >= s.end {
+		start, end, err := s.d.Allocate(ctx, s.bizTag)
+		if err != nil {
+			return 0, 0, err
+		}
+		s.cur, s.end = start, end
+	}
+
+	start := s.cur
+	s.cur = min(s.end, start+n)
+	return start, s.cur, nil
+}
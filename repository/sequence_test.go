@@ -0,0 +1,96 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func newSegments(t *testing.T, step int64) dao.SegmentInter {
+	db := testutil.OpenSqlite(t)
+	require.NoError(t, db.AutoMigrate(&dao.Segment{}))
+	d := dao.NewSegmentDao(db)
+	require.NoError(t, d.Init(context.Background(), repository.PoolBizTag, step))
+	return d
+}
+
+func TestSequenceRepository_Next(t *testing.T) {
+	d := newSegments(t, 10)
+	seq := repository.NewSequenceRepository(d, repository.PoolBizTag)
+	ctx := context.Background()
+
+	next := func(s repository.SequenceRepository, n int64) [2]int64 {
+		start, end, err := s.Next(ctx, n)
+		require.NoError(t, err)
+		return [2]int64{start, end}
+	}
+
+	assert.Equal(t, [2]int64{1, 5}, next(seq, 4))
+	// 号段剩余的ID不足时只返回剩余部分
+	assert.Equal(t, [2]int64{5, 11}, next(seq, 8))
+	// 号段用完后申请新的号段
+	assert.Equal(t, [2]int64{11, 19}, next(seq, 8))
+
+	// 重启后丢弃本地未使用的ID，从新的号段开始
+	restarted := repository.NewSequenceRepository(d, repository.PoolBizTag)
+	assert.Equal(t, [2]int64{21, 24}, next(restarted, 3))
+	assert.Equal(t, [2]int64{19, 21}, next(seq, 8))
+}
+
+func TestSequenceRepository_Concurrent(t *testing.T) {
+	d := newSegments(t, 7)
+	ctx := context.Background()
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		seen = make(map[int64]bool)
+	)
+	// 多个实例共用号段表，每个实例内多个协程并发申请
+	for i := 0; i < 3; i++ {
+		seq := repository.NewSequenceRepository(d, repository.PoolBizTag)
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 20; k++ {
+					start, end, err := seq.Next(ctx, 3)
+					if err != nil {
+						// 乐观锁冲突次数过多时由调用方重试
+						assert.ErrorIs(t, err, dao.ErrSegmentConflict)
+						continue
+					}
+
+					mu.Lock()
+					for id := start; id < end; id++ {
+						assert.False(t, seen[id], id)
+						seen[id] = true
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	assert.NotEmpty(t, seen)
+}
@@ -0,0 +1,254 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reshard
+
+import (
+	"sync/atomic"
+
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/dao"
+	lmt "github.com/TimeWtr/local_message_table"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// MigratingRepository 迁移期间使用的双写GeneratorRepository，写操作先写主分表，成功后再同步写入从分表，
+// 从分表写入失败只记录日志，由Resharder.Verify发现并修复。读操作只读主分表。
+// 切换前旧分表为主、新分表为从，Cutover后新分表为主、旧分表继续双写，便于回滚
+type MigratingRepository struct {
+	// 旧分库分表
+	old repository.GeneratorRepository
+	// 新分库分表
+	new repository.GeneratorRepository
+	// 是否已经切换到新分表
+	cutover atomic.Bool
+	// 日志
+	el *elog.Component
+}
+
+var _ repository.GeneratorRepository = (*MigratingRepository)(nil)
+
+func NewMigratingRepository(old repository.GeneratorRepository, new repository.GeneratorRepository) *MigratingRepository {
+	return &MigratingRepository{
+		old: old,
+		new: new,
+		el:  elog.DefaultLogger,
+	}
+}
+
+// Cutover 切换当前实例的读写到新分表，需要由Resharder持久化阶段，其他实例通过Resharder.Watch跟随
+func (m *MigratingRepository) Cutover() {
+	m.cutover.Store(true)
+}
+
+// Rollback 切换当前实例的读写回旧分表，需要由Resharder持久化阶段，其他实例通过Resharder.Watch跟随
+func (m *MigratingRepository) Rollback() {
+	m.cutover.Store(false)
+}
+
+// roles 返回当前的主从分库分表
+func (m *MigratingRepository) roles() (primary repository.GeneratorRepository, secondary repository.GeneratorRepository) {
+	if m.cutover.Load() {
+		return m.new, m.old
+	}
+
+	return m.old, m.new
+}
+
+func (m *MigratingRepository) Insert(ctx context.Context, data domain.URLData) error {
+	primary, secondary := m.roles()
+	if err := primary.Insert(ctx, data); err != nil {
+		return err
+	}
+
+	m.mirror("Insert", secondary.Insert(ctx, data))
+	return nil
+}
+
+func (m *MigratingRepository) BatchInsert(ctx context.Context, data []domain.URLData) error {
+	primary, secondary := m.roles()
+	if err := primary.BatchInsert(ctx, data); err != nil {
+		return err
+	}
+
+	m.mirror("BatchInsert", secondary.BatchInsert(ctx, data))
+	return nil
+}
+
+func (m *MigratingRepository) Shard(biz string, shortCode string) (dao.ShortCodeInter, error) {
+	primary, secondary := m.roles()
+	pd, err := primary.Shard(biz, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.dual(pd, secondary, biz, shortCode), nil
+}
+
+// ShardTx 事务只作用于主分表，从分表在事务外同步写入，事务回滚时从分表可能多出记录，需要通过Verify发现
+func (m *MigratingRepository) ShardTx(tx *gorm.DB, biz string, shortCode string) (dao.ShortCodeInter, error) {
+	primary, secondary := m.roles()
+	pd, err := primary.ShardTx(tx, biz, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.dual(pd, secondary, biz, shortCode), nil
+}
+
+// Exec 本地消息表事务开启在主分表所在的库上，从分表在事务外同步写入
+func (m *MigratingRepository) Exec(ctx context.Context, biz string, shortCode string, fn repository.TxFunc) error {
+	primary, secondary := m.roles()
+	return primary.Exec(ctx, biz, shortCode, func(ctx context.Context, d dao.ShortCodeInter) ([]lmt.Messages, error) {
+		return fn(ctx, m.dual(d, secondary, biz, shortCode))
+	})
+}
+
+// Shards 全量扫描只扫描主分表
+func (m *MigratingRepository) Shards() []dao.ShortCodeInter {
+	primary, _ := m.roles()
+	return primary.Shards()
+}
+
+func (m *MigratingRepository) GetByID(ctx context.Context, id int64) (dao.ShortCode, error) {
+	primary, _ := m.roles()
+	return primary.GetByID(ctx, id)
+}
+
+func (m *MigratingRepository) FindReusable(ctx context.Context, biz string,
+	creator string, originURL string, now int64) (dao.ShortCode, error) {
+	primary, _ := m.roles()
+	return primary.FindReusable(ctx, biz, creator, originURL, now)
+}
+
+func (m *MigratingRepository) Purge(ctx context.Context, shortCodes []string) error {
+	primary, secondary := m.roles()
+	if err := primary.Purge(ctx, shortCodes); err != nil {
+		return err
+	}
+
+	m.mirror("Purge", secondary.Purge(ctx, shortCodes))
+	return nil
+}
+
+func (m *MigratingRepository) dual(pd dao.ShortCodeInter, secondary repository.GeneratorRepository, biz string, shortCode string) dao.ShortCodeInter {
+	sd, err := secondary.Shard(biz, shortCode)
+	if err != nil {
+		m.mirror("Shard", err)
+		return pd
+	}
+
+	return &dualDao{ShortCodeInter: pd, secondary: sd, mirror: m.mirror}
+}
+
+func (m *MigratingRepository) mirror(op string, err error) {
+	if err == nil {
+		return
+	}
+
+	m.el.Error("双写从分表失败", elog.String("op", op), elog.FieldErr(err))
+}
+
+// dualDao 单个短码分表的双写操作，读操作由内嵌的主分表处理
+type dualDao struct {
+	dao.ShortCodeInter
+	secondary dao.ShortCodeInter
+	mirror    func(op string, err error)
+}
+
+func (d *dualDao) Insert(ctx context.Context, data domain.URLData) error {
+	if err := d.ShortCodeInter.Insert(ctx, data); err != nil {
+		return err
+	}
+
+	d.mirror("Insert", d.secondary.Insert(ctx, data))
+	return nil
+}
+
+func (d *dualDao) BatchInsert(ctx context.Context, data []domain.URLData) error {
+	if err := d.ShortCodeInter.BatchInsert(ctx, data); err != nil {
+		return err
+	}
+
+	d.mirror("BatchInsert", d.secondary.BatchInsert(ctx, data))
+	return nil
+}
+
+func (d *dualDao) Update(ctx context.Context, data domain.URLData) error {
+	if err := d.ShortCodeInter.Update(ctx, data); err != nil {
+		return err
+	}
+
+	d.mirror("Update", d.secondary.Update(ctx, data))
+	return nil
+}
+
+func (d *dualDao) ClearExpiration(ctx context.Context, id int64) error {
+	if err := d.ShortCodeInter.ClearExpiration(ctx, id); err != nil {
+		return err
+	}
+
+	d.mirror("ClearExpiration", d.secondary.ClearExpiration(ctx, id))
+	return nil
+}
+
+func (d *dualDao) Delete(ctx context.Context, id int64) error {
+	if err := d.ShortCodeInter.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	d.mirror("Delete", d.secondary.Delete(ctx, id))
+	return nil
+}
+
+func (d *dualDao) DeleteExpired(ctx context.Context, ids []int64, now int64) ([]dao.ShortCode, error) {
+	res, err := d.ShortCodeInter.DeleteExpired(ctx, ids, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.secondary.DeleteExpired(ctx, ids, now)
+	d.mirror("DeleteExpired", err)
+	return res, nil
+}
+
+func (d *dualDao) Purge(ctx context.Context, shortCodes []string) error {
+	if err := d.ShortCodeInter.Purge(ctx, shortCodes); err != nil {
+		return err
+	}
+
+	d.mirror("Purge", d.secondary.Purge(ctx, shortCodes))
+	return nil
+}
+
+func (d *dualDao) Upsert(ctx context.Context, rows []dao.ShortCode) error {
+	if err := d.ShortCodeInter.Upsert(ctx, rows); err != nil {
+		return err
+	}
+
+	d.mirror("Upsert", d.secondary.Upsert(ctx, rows))
+	return nil
+}
+
+func (d *dualDao) Remove(ctx context.Context, ids []int64) error {
+	if err := d.ShortCodeInter.Remove(ctx, ids); err != nil {
+		return err
+	}
+
+	d.mirror("Remove", d.secondary.Remove(ctx, ids))
+	return nil
+}
@@ -0,0 +1,510 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reshard
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/cespare/xxhash/v2"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultBatchSize 默认每批次迁移的记录数
+	DefaultBatchSize = 1000
+	// DefaultWatchInterval 默认查询迁移阶段的间隔
+	DefaultWatchInterval = 5 * time.Second
+)
+
+// 迁移的阶段，按照顺序推进
+const (
+	// PhaseBackfill 双写已经开启，正在将源分表的数据复制到新分表
+	PhaseBackfill = "backfill"
+	// PhaseVerified 数据复制完成并且校验一致
+	PhaseVerified = "verified"
+	// PhaseCutover 读写已经切换到新分表，旧分表继续双写，便于回滚
+	PhaseCutover = "cutover"
+	// PhaseRollback 读写已经切换回旧分表，需要重新校验后才能再次切换
+	PhaseRollback = "rollback"
+)
+
+var (
+	ErrNotVerified  = errors.New("迁移数据未通过校验，不能切换")
+	ErrInvalidPhase = errors.New("迁移任务当前的阶段不能执行该操作")
+)
+
+// Config 迁移任务的配置
+type Config struct {
+	// 迁移任务名称，迁移进度按照任务名称保存
+	Task string
+	// 每批次迁移的记录数
+	BatchSize int
+	// 新分片算法使用的分片键，需要与新的GeneratorRepository一致
+	KeyFunc repository.KeyFunc
+}
+
+func DefaultConfig(task string) Config {
+	return Config{
+		Task:      task,
+		BatchSize: DefaultBatchSize,
+		KeyFunc:   repository.HashKey,
+	}
+}
+
+// TableReport 单个新分表的校验结果
+type TableReport struct {
+	Table string
+	// 源分表中应该落到该分表的记录数和校验和
+	SrcCount    int64
+	SrcChecksum uint64
+	// 新分表中实际的记录数和校验和
+	DstCount    int64
+	DstChecksum uint64
+}
+
+func (t TableReport) OK() bool {
+	return t.SrcCount == t.DstCount && t.SrcChecksum == t.DstChecksum
+}
+
+// Resharder 在线迁移分库分表，迁移流程：
+// 1. 服务使用MigratingRepository双写新旧分表，读取仍然使用旧分表，所有实例通过Watch跟随持久化的迁移阶段
+// 2. Backfill按照主键分批将旧分表的数据复制到新分表，每批次完成后保存进度，崩溃后从进度继续
+// 3. Verify按照新分表统计新旧两侧的记录数和校验和，不一致的分表按照旧分表修复后需要重新校验
+// 4. Cutover校验通过后切换读写到新分表，Rollback切换回旧分表
+type Resharder struct {
+	cfg Config
+	// 迁移前后的分库分表
+	src data_source.Factory
+	dst data_source.Factory
+	// 迁移进度
+	d dao.ReshardInter
+	// 日志
+	el *elog.Component
+}
+
+func NewResharder(cfg Config, src data_source.Factory, dst data_source.Factory, d dao.ReshardInter) *Resharder {
+	return &Resharder{
+		cfg: cfg,
+		src: src,
+		dst: dst,
+		d:   d,
+		el:  elog.DefaultLogger,
+	}
+}
+
+// Phase 查询迁移任务当前的阶段，服务重启后根据阶段恢复MigratingRepository的读写方向
+func (r *Resharder) Phase(ctx context.Context) (string, error) {
+	return r.d.GetPhase(ctx, r.cfg.Task)
+}
+
+// Restore 服务启动时恢复MigratingRepository的读写方向
+func (r *Resharder) Restore(ctx context.Context, repo *MigratingRepository) error {
+	phase, err := r.Phase(ctx)
+	if err != nil {
+		return err
+	}
+
+	apply(repo, phase)
+	return nil
+}
+
+// Watch 按照间隔查询迁移阶段并切换MigratingRepository的读写方向，直到ctx被取消。
+// 切换和回滚只在执行的实例上立即生效，其他实例最多延迟一个间隔跟随
+func (r *Resharder) Watch(ctx context.Context, repo *MigratingRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Restore(ctx, repo); err != nil {
+				r.el.Error("查询迁移阶段失败", elog.FieldErr(err), elog.String("task", r.cfg.Task))
+			}
+		}
+	}
+}
+
+// apply 切换后的阶段读写新分表，其他阶段读写旧分表
+func apply(repo *MigratingRepository, phase string) {
+	if phase == PhaseCutover {
+		repo.Cutover()
+		return
+	}
+
+	repo.Rollback()
+}
+
+// expect 查询当前的阶段，不是phases中的阶段时返回ErrInvalidPhase
+func (r *Resharder) expect(ctx context.Context, op string, phases ...string) (string, error) {
+	phase, err := r.Phase(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(phases, phase) {
+		return "", fmt.Errorf("%w: 迁移任务%s处于%q阶段，不能执行%s", ErrInvalidPhase, r.cfg.Task, phase, op)
+	}
+
+	return phase, nil
+}
+
+// Backfill 将所有旧分表的数据复制到新分表，已经完成的分表直接跳过，未完成的分表从上次保存的主键继续。
+// 写入新分表使用Upsert，重复复制同一批数据是幂等的，双写的数据也会被旧分表中的最新数据覆盖。
+// 已经校验通过或者切换后不能再执行，避免阶段被退回
+func (r *Resharder) Backfill(ctx context.Context) error {
+	if _, err := r.expect(ctx, "Backfill", "", PhaseBackfill, PhaseRollback); err != nil {
+		return err
+	}
+
+	if err := r.d.SetPhase(ctx, r.cfg.Task, PhaseBackfill); err != nil {
+		return err
+	}
+
+	cps, err := r.d.GetCheckpoints(ctx, r.cfg.Task)
+	if err != nil {
+		return err
+	}
+
+	progress := make(map[string]dao.ReshardCheckpoint, len(cps))
+	for _, cp := range cps {
+		progress[cp.SrcTable] = cp
+	}
+
+	for _, src := range r.src.AllDst() {
+		cp, ok := progress[src.Table]
+		if !ok {
+			cp = dao.ReshardCheckpoint{Task: r.cfg.Task, SrcTable: src.Table}
+		}
+
+		if cp.Done {
+			continue
+		}
+
+		if err = r.backfillTable(ctx, src, cp); err != nil {
+			return fmt.Errorf("迁移分表%s失败: %w", src.Table, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Resharder) backfillTable(ctx context.Context, src data_source.Dst, cp dao.ReshardCheckpoint) error {
+	d := dao.NewShardShortCodeDao(src.DB, src.Table)
+	for {
+		rows, err := d.Scan(ctx, cp.LastID, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(rows) == 0 {
+			cp.Done = true
+			return r.d.SaveCheckpoint(ctx, cp)
+		}
+
+		groups, err := r.route(rows)
+		if err != nil {
+			return err
+		}
+
+		for dst, items := range groups {
+			if err = dao.NewShardShortCodeDao(dst.DB, dst.Table).Upsert(ctx, items); err != nil {
+				return err
+			}
+		}
+
+		cp.LastID = rows[len(rows)-1].ID
+		cp.Copied += int64(len(rows))
+		if err = r.d.SaveCheckpoint(ctx, cp); err != nil {
+			return err
+		}
+
+		r.el.Info("迁移分表进度",
+			elog.String("table", src.Table),
+			elog.Int64("lastID", cp.LastID),
+			elog.Int64("copied", cp.Copied))
+	}
+}
+
+// Verify 按照新分表统计记录数和校验和，旧分表的记录按照新的分片算法归属到新分表后统计。
+// 校验和是每条记录哈希值的累加，与记录的顺序无关，更新时间在双写时两侧不一致，不参与计算。
+// 扫描前记录旧分表的最大主键作为快照，两侧都只统计快照之内的记录，避免校验期间新增的双写数据导致不一致。
+// 不一致的新分表按照旧分表逐条修复，返回包装了ErrNotVerified的错误，修复后需要重新校验
+func (r *Resharder) Verify(ctx context.Context) ([]TableReport, error) {
+	if _, err := r.expect(ctx, "Verify", PhaseBackfill, PhaseVerified, PhaseRollback); err != nil {
+		return nil, err
+	}
+
+	hwm, err := r.highWaterMark(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make(map[data_source.Dst]*TableReport)
+	for _, dst := range r.dst.AllDst() {
+		reports[dst] = &TableReport{Table: dst.Table}
+	}
+
+	err = r.scanSrc(ctx, hwm, func(dst data_source.Dst, items []dao.ShortCode) error {
+		report, ok := reports[dst]
+		if !ok {
+			return fmt.Errorf("分表%s不在新的分库分表中", dst.Table)
+		}
+		for _, item := range items {
+			report.SrcCount++
+			report.SrcChecksum += checksum(item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]TableReport, 0, len(reports))
+	var mismatched []string
+	for _, dst := range r.dst.AllDst() {
+		report := reports[dst]
+		err = r.scan(ctx, dst, hwm, func(rows []dao.ShortCode) error {
+			for _, row := range rows {
+				report.DstCount++
+				report.DstChecksum += checksum(row)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *report)
+
+		if report.OK() {
+			continue
+		}
+		mismatched = append(mismatched, dst.Table)
+		if err = r.reconcile(ctx, dst, hwm); err != nil {
+			return res, fmt.Errorf("修复分表%s失败: %w", dst.Table, err)
+		}
+	}
+
+	if len(mismatched) > 0 {
+		if err = r.d.SetPhase(ctx, r.cfg.Task, PhaseBackfill); err != nil {
+			return res, err
+		}
+		return res, fmt.Errorf("%w: 分表%s已经按照旧分表修复，需要重新校验",
+			ErrNotVerified, strings.Join(mismatched, ","))
+	}
+
+	return res, r.d.SetPhase(ctx, r.cfg.Task, PhaseVerified)
+}
+
+// reconcile 按照旧分表修复新分表快照之内的记录，新分表缺少或者内容不一致的记录从旧分表重新复制，
+// 旧分表中不存在的记录(比如主分表事务回滚但双写已经写入)直接删除。双写丢失的数据只能由这里修复
+func (r *Resharder) reconcile(ctx context.Context, dst data_source.Dst, hwm int64) error {
+	// 旧分表中应该落到该分表的记录的校验和
+	want := make(map[int64]uint64)
+	err := r.scanSrc(ctx, hwm, func(d data_source.Dst, items []dao.ShortCode) error {
+		if d != dst {
+			return nil
+		}
+		for _, item := range items {
+			want[item.ID] = checksum(item)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dd := dao.NewShardShortCodeDao(dst.DB, dst.Table)
+	var extra []int64
+	err = r.scan(ctx, dst, hwm, func(rows []dao.ShortCode) error {
+		for _, row := range rows {
+			sum, ok := want[row.ID]
+			switch {
+			case !ok:
+				extra = append(extra, row.ID)
+			case sum == checksum(row):
+				delete(want, row.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 先删除多余的记录，避免短码唯一索引和需要复制的记录冲突
+	for batch := range slices.Chunk(extra, r.cfg.BatchSize) {
+		if err = dd.Remove(ctx, batch); err != nil {
+			return err
+		}
+	}
+
+	stale := len(want)
+	err = r.scanSrc(ctx, hwm, func(d data_source.Dst, items []dao.ShortCode) error {
+		if d != dst || len(want) == 0 {
+			return nil
+		}
+
+		rows := slices.DeleteFunc(items, func(item dao.ShortCode) bool {
+			_, ok := want[item.ID]
+			return !ok
+		})
+		return dd.Upsert(ctx, rows)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.el.Warn("修复迁移不一致的分表",
+		elog.String("table", dst.Table),
+		elog.Int("removed", len(extra)),
+		elog.Int("copied", stale))
+	return nil
+}
+
+// Cutover 校验通过后切换读写到新分表，切换后的阶段会持久化，其他实例通过Watch跟随，服务重启后通过Restore恢复
+func (r *Resharder) Cutover(ctx context.Context, repo *MigratingRepository) error {
+	if _, err := r.expect(ctx, "Cutover", PhaseVerified, PhaseCutover); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotVerified, err)
+	}
+
+	if err := r.d.SetPhase(ctx, r.cfg.Task, PhaseCutover); err != nil {
+		return err
+	}
+
+	repo.Cutover()
+	return nil
+}
+
+// Rollback 切换读写回旧分表，回滚的阶段会持久化，其他实例通过Watch跟随，再次切换前需要重新校验
+func (r *Resharder) Rollback(ctx context.Context, repo *MigratingRepository) error {
+	if _, err := r.expect(ctx, "Rollback", PhaseCutover, PhaseRollback); err != nil {
+		return err
+	}
+
+	if err := r.d.SetPhase(ctx, r.cfg.Task, PhaseRollback); err != nil {
+		return err
+	}
+
+	repo.Rollback()
+	return nil
+}
+
+// highWaterMark 所有旧分表的最大主键，主键是全局递增的分布式ID，之后写入的记录主键都更大
+func (r *Resharder) highWaterMark(ctx context.Context) (int64, error) {
+	var hwm int64
+	for _, src := range r.src.AllDst() {
+		id, err := dao.NewShardShortCodeDao(src.DB, src.Table).MaxID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		hwm = max(hwm, id)
+	}
+
+	return hwm, nil
+}
+
+// scanSrc 扫描所有旧分表中快照之内的记录，按照新的分片算法分组后交给fn
+func (r *Resharder) scanSrc(ctx context.Context, hwm int64,
+	fn func(dst data_source.Dst, items []dao.ShortCode) error) error {
+	for _, src := range r.src.AllDst() {
+		err := r.scan(ctx, src, hwm, func(rows []dao.ShortCode) error {
+			groups, err := r.route(rows)
+			if err != nil {
+				return err
+			}
+
+			for dst, items := range groups {
+				if err = fn(dst, items); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// route 按照新的分片算法对记录分组
+func (r *Resharder) route(rows []dao.ShortCode) (map[data_source.Dst][]dao.ShortCode, error) {
+	groups := make(map[data_source.Dst][]dao.ShortCode)
+	for _, row := range rows {
+		dst, err := r.dst.GetDB(r.cfg.KeyFunc(row.Biz, row.ShortCode))
+		if err != nil {
+			return nil, fmt.Errorf("短码%s分片失败: %w", row.ShortCode, err)
+		}
+		groups[dst] = append(groups[dst], row)
+	}
+
+	return groups, nil
+}
+
+// scan 按照主键分批扫描分表中主键不超过hwm的记录
+func (r *Resharder) scan(ctx context.Context, ds data_source.Dst, hwm int64, fn func(rows []dao.ShortCode) error) error {
+	d := dao.NewShardShortCodeDao(ds.DB, ds.Table)
+	var lastID int64
+	for {
+		rows, err := d.Scan(ctx, lastID, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		// 记录按照主键排序，超过快照的记录都在最后
+		end, _ := slices.BinarySearchFunc(rows, hwm+1, func(row dao.ShortCode, id int64) int {
+			return cmp.Compare(row.ID, id)
+		})
+		if end == 0 {
+			return nil
+		}
+
+		if err = fn(rows[:end]); err != nil {
+			return err
+		}
+		if end < len(rows) {
+			return nil
+		}
+		lastID = rows[end-1].ID
+	}
+}
+
+// checksum 计算单条记录的哈希值，软删除只区分是否删除，不比较删除时间
+func checksum(row dao.ShortCode) uint64 {
+	return xxhash.Sum64String(strings.Join([]string{
+		strconv.FormatInt(row.ID, 10),
+		row.Biz,
+		row.ShortCode,
+		row.OriginalURL,
+		strconv.FormatInt(row.ExpireAt, 10),
+		row.Comment,
+		row.Creator,
+		strconv.FormatBool(row.Custom),
+		strconv.FormatBool(row.DeleteTime > 0),
+	}, "\x00"))
+}
@@ -0,0 +1,241 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reshard
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator/data_source"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+type testEnv struct {
+	src, dst data_source.Factory
+	old, new repository.GeneratorRepository
+	d        dao.ReshardInter
+	cfg      Config
+}
+
+// newTestEnv 旧分库分表有2个分表，新分库分表有3个分表，旧分表写入ID为1、3、5...的n条记录
+func newTestEnv(t *testing.T, n int) *testEnv {
+	meta := testutil.OpenSqlite(t)
+	require.NoError(t, meta.AutoMigrate(&dao.ReshardTask{}, &dao.ReshardCheckpoint{}))
+
+	env := &testEnv{
+		d:   dao.NewReshardDao(meta),
+		cfg: DefaultConfig("test"),
+	}
+	env.src, _ = testutil.NewShards(t, 2)
+	env.dst, _ = testutil.NewShards(t, 3)
+	env.cfg.BatchSize = 4
+	env.old = repository.NewGeneratorRepository(env.src, testutil.Pusher)
+	env.new = repository.NewGeneratorRepository(env.dst, testutil.Pusher)
+
+	data := make([]domain.URLData, 0, n)
+	for i := 0; i < n; i++ {
+		data = append(data, domain.URLData{
+			ID:        int64(2*i + 1),
+			Biz:       "test",
+			OriginURL: fmt.Sprintf("https://example.com/%d", i),
+			ShortCode: fmt.Sprintf("code%d", i),
+		})
+	}
+	require.NoError(t, env.old.BatchInsert(context.Background(), data))
+	return env
+}
+
+func (e *testEnv) resharder(d dao.ReshardInter) *Resharder {
+	return NewResharder(e.cfg, e.src, e.dst, d)
+}
+
+func count(t *testing.T, f data_source.Factory) int {
+	var total int
+	for _, dst := range f.AllDst() {
+		rows, err := dao.NewShardShortCodeDao(dst.DB, dst.Table).Scan(context.Background(), 0, 1000)
+		require.NoError(t, err)
+		total += len(rows)
+	}
+	return total
+}
+
+func phase(t *testing.T, r *Resharder) string {
+	p, err := r.Phase(context.Background())
+	require.NoError(t, err)
+	return p
+}
+
+// crashReshardDao 第fail次保存进度时失败，模拟迁移过程中崩溃
+type crashReshardDao struct {
+	dao.ReshardInter
+	fail  int
+	saves int
+}
+
+func (c *crashReshardDao) SaveCheckpoint(ctx context.Context, cp dao.ReshardCheckpoint) error {
+	c.saves++
+	if c.saves == c.fail {
+		return errors.New("mock crash")
+	}
+	return c.ReshardInter.SaveCheckpoint(ctx, cp)
+}
+
+func TestResharder_BackfillResume(t *testing.T) {
+	env := newTestEnv(t, 30)
+	ctx := context.Background()
+
+	err := env.resharder(&crashReshardDao{ReshardInter: env.d, fail: 3}).Backfill(ctx)
+	assert.ErrorContains(t, err, "mock crash")
+
+	cps, err := env.d.GetCheckpoints(ctx, env.cfg.Task)
+	require.NoError(t, err)
+	require.Len(t, cps, 1)
+	assert.False(t, cps[0].Done)
+	assert.Equal(t, int64(8), cps[0].Copied)
+
+	// 从保存的进度继续，已经迁移的批次不会重复计数
+	require.NoError(t, env.resharder(env.d).Backfill(ctx))
+	assert.Equal(t, 30, count(t, env.dst))
+
+	cps, err = env.d.GetCheckpoints(ctx, env.cfg.Task)
+	require.NoError(t, err)
+	require.Len(t, cps, 2)
+	var copied int64
+	for _, cp := range cps {
+		assert.True(t, cp.Done)
+		copied += cp.Copied
+	}
+	assert.Equal(t, int64(30), copied)
+	assert.Equal(t, PhaseBackfill, phase(t, env.resharder(env.d)))
+}
+
+func TestResharder_VerifyMismatch(t *testing.T) {
+	env := newTestEnv(t, 30)
+	r := env.resharder(env.d)
+	ctx := context.Background()
+
+	require.NoError(t, r.Backfill(ctx))
+	reports, err := r.Verify(ctx)
+	require.NoError(t, err)
+	assert.Len(t, reports, 3)
+	assert.Equal(t, PhaseVerified, phase(t, r))
+
+	// 双写丢失的修改、删除和事务回滚后多出的记录
+	changed, err := env.new.Shard("test", "code1")
+	require.NoError(t, err)
+	require.NoError(t, changed.Update(ctx, domain.URLData{ID: 3, OriginURL: "https://stale.com"}))
+	lost, err := env.new.Shard("test", "code2")
+	require.NoError(t, err)
+	require.NoError(t, lost.Remove(ctx, []int64{5}))
+	extra := env.dst.AllDst()[0]
+	require.NoError(t, dao.NewShardShortCodeDao(extra.DB, extra.Table).Upsert(ctx, []dao.ShortCode{
+		{ID: 2, Biz: "test", OriginalURL: "https://extra.com", ShortCode: "extra"},
+	}))
+	// 校验开始后写入的记录超过快照，不参与校验
+	require.NoError(t, dao.NewShardShortCodeDao(extra.DB, extra.Table).Upsert(ctx, []dao.ShortCode{
+		{ID: 1000, Biz: "test", OriginalURL: "https://new.com", ShortCode: "new"},
+	}))
+
+	reports, err = r.Verify(ctx)
+	assert.ErrorIs(t, err, ErrNotVerified)
+	var failed int
+	for _, report := range reports {
+		if !report.OK() {
+			failed++
+		}
+	}
+	assert.NotZero(t, failed)
+	assert.Equal(t, PhaseBackfill, phase(t, r))
+	assert.ErrorIs(t, r.Cutover(ctx, NewMigratingRepository(env.old, env.new)), ErrNotVerified)
+
+	// 不一致的分表已经按照旧分表修复
+	_, err = r.Verify(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseVerified, phase(t, r))
+
+	sc, err := changed.GetURLByID(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", sc.OriginalURL)
+	_, err = lost.GetURLByID(ctx, 5)
+	assert.NoError(t, err)
+	_, err = env.new.GetByID(ctx, 2)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = env.new.GetByID(ctx, 1000)
+	assert.NoError(t, err)
+}
+
+func TestResharder_CutoverRestore(t *testing.T) {
+	env := newTestEnv(t, 10)
+	r := env.resharder(env.d)
+	ctx := context.Background()
+
+	repo := NewMigratingRepository(env.old, env.new)
+	assert.ErrorIs(t, r.Cutover(ctx, repo), ErrNotVerified)
+	_, err := r.Verify(ctx)
+	assert.ErrorIs(t, err, ErrInvalidPhase)
+
+	require.NoError(t, r.Backfill(ctx))
+	_, err = r.Verify(ctx)
+	require.NoError(t, err)
+	// 校验通过后不能退回到复制阶段
+	assert.ErrorIs(t, r.Backfill(ctx), ErrInvalidPhase)
+
+	require.NoError(t, r.Cutover(ctx, repo))
+	assert.True(t, repo.cutover.Load())
+	assert.ErrorIs(t, r.Backfill(ctx), ErrInvalidPhase)
+
+	// 只写入新分表的记录，用于判断读取的方向
+	d, err := env.new.Shard("test", "only-new")
+	require.NoError(t, err)
+	require.NoError(t, d.Insert(ctx, domain.URLData{ID: 100, Biz: "test", ShortCode: "only-new"}))
+
+	// 其他实例重启后恢复切换后的读写方向
+	other := NewMigratingRepository(env.old, env.new)
+	require.NoError(t, r.Restore(ctx, other))
+	_, err = other.GetByID(ctx, 100)
+	assert.NoError(t, err)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go r.Watch(watchCtx, other, 10*time.Millisecond)
+
+	// 回滚持久化后其他实例跟随切换回旧分表
+	require.NoError(t, r.Rollback(ctx, repo))
+	assert.False(t, repo.cutover.Load())
+	assert.Equal(t, PhaseRollback, phase(t, r))
+	require.Eventually(t, func() bool {
+		return !other.cutover.Load()
+	}, time.Second, 10*time.Millisecond)
+	_, err = other.GetByID(ctx, 100)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// 回滚后需要重新校验才能再次切换
+	assert.ErrorIs(t, r.Cutover(ctx, repo), ErrNotVerified)
+	_, err = r.Verify(ctx)
+	require.NoError(t, err)
+	require.NoError(t, r.Cutover(ctx, repo))
+	require.Eventually(t, func() bool {
+		return other.cutover.Load()
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/TimeWtr/generator"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultCustomCodeMinLen 自定义短码的默认最小长度，过短的短码容易与预生成短码冲突
+	DefaultCustomCodeMinLen = 4
+	// DefaultCustomCodeMaxLen 自定义短码的默认最大长度
+	DefaultCustomCodeMaxLen = 32
+)
+
+// DefaultReservedWords 默认的保留字，这些路径被跳转服务或管理后台占用，不能作为自定义短码
+var DefaultReservedWords = []string{
+	"admin", "api", "login", "logout", "static", "assets",
+	"health", "metrics", "debug", "favicon.ico", "robots.txt",
+}
+
+// CustomCodeRule 自定义短码的校验规则
+type CustomCodeRule struct {
+	// 最小长度
+	MinLen int
+	// 最大长度
+	MaxLen int
+	// 保留字，不区分大小写
+	Reserved []string
+}
+
+// DefaultCustomCodeRule 默认的自定义短码校验规则
+func DefaultCustomCodeRule() CustomCodeRule {
+	return CustomCodeRule{
+		MinLen:   DefaultCustomCodeMinLen,
+		MaxLen:   DefaultCustomCodeMaxLen,
+		Reserved: DefaultReservedWords,
+	}
+}
+
+// CustomCodeHandler 自定义短码处理器，请求没有指定自定义短码时直接交给下一个处理器，
+// 指定了自定义短码时校验字符集、长度和保留字，并通过过滤器和数据库确认短码未被占用，
+// 校验通过后后续的哈希计算和短码池处理器会跳过，直接使用自定义短码持久化
+type CustomCodeHandler struct {
+	BaseHandler
+	cc   cache.Cacher
+	repo repository.GeneratorRepository
+	// 校验规则
+	rule CustomCodeRule
+	// 小写的保留字集合
+	reserved map[string]struct{}
+}
+
+func NewCustomCodeHandler(cc cache.Cacher, repo repository.GeneratorRepository, rule CustomCodeRule) Handler {
+	reserved := make(map[string]struct{}, len(rule.Reserved))
+	for _, word := range rule.Reserved {
+		reserved[strings.ToLower(word)] = struct{}{}
+	}
+
+	return &CustomCodeHandler{
+		cc:       cc,
+		repo:     repo,
+		rule:     rule,
+		reserved: reserved,
+	}
+}
+
+func (c *CustomCodeHandler) Process(ctx context.Context, req *Request, resp *Response) error {
+	if c.next == nil {
+		return errors.New("哈希计算处理器不存在")
+	}
+
+	if req.CustomCode == "" {
+		return c.next.Process(ctx, req, resp)
+	}
+
+	if err := c.validate(req.CustomCode); err != nil {
+		return err
+	}
+
+	taken, err := c.taken(ctx, req.Biz, req.CustomCode)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return generator.ErrCustomCodeTaken
+	}
+
+	resp.ShortCode = req.CustomCode
+	if err = c.next.Process(ctx, req, resp); err != nil {
+		return err
+	}
+
+	// 持久化成功后写入过滤器，写入失败只会导致后续的重复校验多查一次数据库
+	_ = c.cc.Add(ctx, cache.BFKey, req.CustomCode)
+	return nil
+}
+
+// validate 校验自定义短码的长度、字符集和保留字，只允许数字、大小写字母、'-'和'_'
+func (c *CustomCodeHandler) validate(code string) error {
+	if len(code) < c.rule.MinLen || len(code) > c.rule.MaxLen {
+		return generator.ErrCustomCodeInvalid
+	}
+
+	for i := 0; i < len(code); i++ {
+		ch := code[i]
+		switch {
+		case ch >= '0' && ch <= '9', ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch == '-', ch == '_':
+		default:
+			return generator.ErrCustomCodeInvalid
+		}
+	}
+
+	if _, ok := c.reserved[strings.ToLower(code)]; ok {
+		return generator.ErrCustomCodeInvalid
+	}
+
+	return nil
+}
+
+// taken 查询自定义短码是否已经被占用，过滤器返回"可能存在"时需要到数据库中二次确认
+func (c *CustomCodeHandler) taken(ctx context.Context, biz string, code string) (bool, error) {
+	exists, err := c.cc.Exists(ctx, code)
+	if err != nil {
+		return false, err
+	}
+
+	if !exists {
+		return false, nil
+	}
+
+	d, err := c.repo.Shard(biz, code)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = d.GetURLByShortCode(ctx, code)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	intrv1 "github.com/TimeWtr/generator/api/proto/gen/intr.v1"
+	"github.com/TimeWtr/generator/domain"
+)
+
+// ExpirationPolicy 业务的过期策略
+type ExpirationPolicy struct {
+	// 未指定过期方式时的有效期，0表示永不过期
+	Default time.Duration
+	// 最长有效期，0表示不限制，限制了最长有效期时不允许永不过期
+	Max time.Duration
+}
+
+// ExpirationConfig 过期策略的配置
+type ExpirationConfig struct {
+	// 默认的过期策略
+	Default ExpirationPolicy
+	// 业务自定义的过期策略，没有配置的业务使用默认的过期策略
+	Biz map[string]ExpirationPolicy
+}
+
+func DefaultExpirationConfig() ExpirationConfig {
+	return ExpirationConfig{
+		Default: ExpirationPolicy{
+			Default: generator.ThirtyDays * 24 * time.Hour,
+		},
+	}
+}
+
+// WithExpiration 替换默认的过期策略
+func WithExpiration(cfg ExpirationConfig) Option {
+	return func(s *Service) {
+		s.expiration = cfg
+	}
+}
+
+func (c ExpirationConfig) policy(biz string) ExpirationPolicy {
+	if p, ok := c.Biz[biz]; ok {
+		return p
+	}
+
+	return c.Default
+}
+
+// ExpireAt 按照过期策略计算过期时间的毫秒时间戳，返回domain.NeverExpire表示永不过期
+func (p ExpirationPolicy) ExpireAt(e domain.Expiry, now time.Time) (int64, error) {
+	var expireAt int64
+	switch e.Kind {
+	case domain.ExpiryDefault:
+		d := p.Default
+		if d == 0 {
+			if p.Max == 0 {
+				return domain.NeverExpire, nil
+			}
+			d = p.Max
+		}
+		expireAt = now.Add(d).UnixMilli()
+	case domain.ExpiryAt:
+		if e.At <= now.UnixMilli() {
+			return 0, fmt.Errorf("%w: 过期时间早于当前时间", generator.ErrExpirationInvalid)
+		}
+		expireAt = e.At
+	case domain.ExpiryAfter:
+		if e.After <= 0 {
+			return 0, fmt.Errorf("%w: 有效期必须大于0", generator.ErrExpirationInvalid)
+		}
+		expireAt = now.Add(e.After).UnixMilli()
+	case domain.ExpiryNever:
+		if p.Max > 0 {
+			return 0, fmt.Errorf("%w: 业务不允许永不过期", generator.ErrExpirationInvalid)
+		}
+		return domain.NeverExpire, nil
+	default:
+		return 0, generator.ErrExpirationInvalid
+	}
+
+	if p.Max > 0 && expireAt > now.Add(p.Max).UnixMilli() {
+		return 0, fmt.Errorf("%w: 超过最长有效期%s", generator.ErrExpirationInvalid, p.Max)
+	}
+
+	return expireAt, nil
+}
+
+// expiryFromMeta 解析请求元数据中的过期方式，expiry优先，兼容按天指定的expiration。
+// 有效期超过time.Duration能表示的范围时会溢出为错误的值，直接返回ErrExpirationInvalid
+func expiryFromMeta(meta *intrv1.Metadata) (domain.Expiry, error) {
+	switch v := meta.GetExpiry().(type) {
+	case *intrv1.Metadata_ExpireAt:
+		return domain.Expiry{Kind: domain.ExpiryAt, At: v.ExpireAt}, nil
+	case *intrv1.Metadata_TtlSeconds:
+		after, err := durationOf(int64(v.TtlSeconds), time.Second)
+		if err != nil {
+			return domain.Expiry{}, err
+		}
+		return domain.Expiry{Kind: domain.ExpiryAfter, After: after}, nil
+	case *intrv1.Metadata_NeverExpire:
+		if v.NeverExpire {
+			return domain.Expiry{Kind: domain.ExpiryNever}, nil
+		}
+	}
+
+	if days := meta.GetExpiration(); days != 0 {
+		after, err := durationOf(int64(days), 24*time.Hour)
+		if err != nil {
+			return domain.Expiry{}, err
+		}
+		return domain.Expiry{Kind: domain.ExpiryAfter, After: after}, nil
+	}
+
+	return domain.Expiry{Kind: domain.ExpiryDefault}, nil
+}
+
+// durationOf 计算n个unit的时长，超过time.Duration的范围时返回ErrExpirationInvalid
+func durationOf(n int64, unit time.Duration) (time.Duration, error) {
+	if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return 0, fmt.Errorf("%w: 有效期超过了%s", generator.ErrExpirationInvalid, time.Duration(math.MaxInt64))
+	}
+
+	return time.Duration(n) * unit, nil
+}
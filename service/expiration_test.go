@@ -0,0 +1,143 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	intrv1 "github.com/TimeWtr/generator/api/proto/gen/intr.v1"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpirationPolicy_ExpireAt(t *testing.T) {
+	now := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	testCases := []struct {
+		name    string
+		policy  ExpirationPolicy
+		expiry  domain.Expiry
+		want    int64
+		wantErr error
+	}{
+		{
+			name:   "default duration",
+			policy: ExpirationPolicy{Default: 7 * day},
+			expiry: domain.Expiry{Kind: domain.ExpiryDefault},
+			want:   now.Add(7 * day).UnixMilli(),
+		},
+		{
+			name:   "default never expire",
+			policy: ExpirationPolicy{},
+			expiry: domain.Expiry{Kind: domain.ExpiryDefault},
+			want:   domain.NeverExpire,
+		},
+		{
+			name:   "default falls back to max",
+			policy: ExpirationPolicy{Max: 30 * day},
+			expiry: domain.Expiry{Kind: domain.ExpiryDefault},
+			want:   now.Add(30 * day).UnixMilli(),
+		},
+		{
+			name:   "absolute timestamp",
+			policy: ExpirationPolicy{Max: 30 * day},
+			expiry: domain.Expiry{Kind: domain.ExpiryAt, At: now.Add(time.Hour).UnixMilli()},
+			want:   now.Add(time.Hour).UnixMilli(),
+		},
+		{
+			name:    "absolute timestamp in the past",
+			expiry:  domain.Expiry{Kind: domain.ExpiryAt, At: now.Add(-time.Hour).UnixMilli()},
+			wantErr: generator.ErrExpirationInvalid,
+		},
+		{
+			name:   "duration",
+			expiry: domain.Expiry{Kind: domain.ExpiryAfter, After: 90 * time.Minute},
+			want:   now.Add(90 * time.Minute).UnixMilli(),
+		},
+		{
+			name:    "duration exceeds max",
+			policy:  ExpirationPolicy{Max: 30 * day},
+			expiry:  domain.Expiry{Kind: domain.ExpiryAfter, After: 31 * day},
+			wantErr: generator.ErrExpirationInvalid,
+		},
+		{
+			name:   "never expire",
+			expiry: domain.Expiry{Kind: domain.ExpiryNever},
+			want:   domain.NeverExpire,
+		},
+		{
+			name:    "never expire with max",
+			policy:  ExpirationPolicy{Max: 30 * day},
+			expiry:  domain.Expiry{Kind: domain.ExpiryNever},
+			wantErr: generator.ErrExpirationInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.policy.ExpireAt(tc.expiry, now)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestExpiryFromMeta(t *testing.T) {
+	testCases := []struct {
+		name    string
+		meta    *intrv1.Metadata
+		want    domain.Expiry
+		wantErr error
+	}{
+		{
+			name: "ttl seconds",
+			meta: &intrv1.Metadata{Expiry: &intrv1.Metadata_TtlSeconds{TtlSeconds: 60}},
+			want: domain.Expiry{Kind: domain.ExpiryAfter, After: time.Minute},
+		},
+		{
+			name:    "ttl seconds overflow",
+			meta:    &intrv1.Metadata{Expiry: &intrv1.Metadata_TtlSeconds{TtlSeconds: math.MaxInt64/int64(time.Second) + 1}},
+			wantErr: generator.ErrExpirationInvalid,
+		},
+		{
+			name:    "legacy days overflow",
+			meta:    &intrv1.Metadata{Expiration: math.MaxInt64/int64(24*time.Hour) + 1},
+			wantErr: generator.ErrExpirationInvalid,
+		},
+		{
+			name: "default",
+			meta: &intrv1.Metadata{},
+			want: domain.Expiry{Kind: domain.ExpiryDefault},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := expiryFromMeta(tc.meta)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
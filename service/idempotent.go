@@ -0,0 +1,169 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// DefaultIdempotencyKeyTTL 客户端幂等键的默认保留时间，覆盖客户端超时重试的时间窗口
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyConfig 幂等生成的配置
+type IdempotencyConfig struct {
+	// 开启URL去重的业务，同一创建者重复提交相同的原始URL时返回已有的未过期短链
+	DedupBiz map[string]bool
+	// 客户端幂等键的保留时间
+	KeyTTL time.Duration
+}
+
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		KeyTTL: DefaultIdempotencyKeyTTL,
+	}
+}
+
+// IdempotentHandler 幂等处理器，请求携带幂等键时，保留期内相同幂等键的请求直接返回第一次生成的结果；
+// 业务开启URL去重时，同一创建者已经为原始URL生成过未过期的短链，则直接返回已有的短链，不再生成新的短码
+type IdempotentHandler struct {
+	BaseHandler
+	ic   cache.IdempotencyCache
+	repo repository.GeneratorRepository
+	// 是否开启URL去重
+	dedup bool
+	// 幂等键的保留时间
+	ttl time.Duration
+}
+
+func NewIdempotentHandler(ic cache.IdempotencyCache, repo repository.GeneratorRepository,
+	dedup bool, ttl time.Duration) Handler {
+	return &IdempotentHandler{
+		ic:    ic,
+		repo:  repo,
+		dedup: dedup,
+		ttl:   ttl,
+	}
+}
+
+func (h *IdempotentHandler) Process(ctx context.Context, req *Request, resp *Response) error {
+	if h.next == nil {
+		return errors.New("ID处理器不存在")
+	}
+
+	if req.IdempotencyKey == "" || h.ic == nil {
+		return h.reuseOrNext(ctx, req, resp)
+	}
+
+	key := idempotencyKey(req)
+	val, acquired, err := h.ic.Acquire(ctx, key, h.ttl)
+	if err != nil {
+		return err
+	}
+
+	if !acquired {
+		if val == "" {
+			return generator.ErrRequestInProgress
+		}
+
+		var rec idempotencyRecord
+		if err = json.Unmarshal([]byte(val), &rec); err != nil {
+			return err
+		}
+
+		// 相同的幂等键携带了不同的请求内容，不能返回第一次的结果，也不能覆盖已有短码的映射缓存
+		if rec.Biz != req.Biz || rec.Creator != req.Creator || rec.OriginURL != req.OriginURL {
+			return generator.ErrIdempotencyConflict
+		}
+
+		resp.ID, resp.OriginURL, resp.ShortCode, resp.ExpireAt = rec.ID, rec.OriginURL, rec.ShortCode, rec.ExpireAt
+		resp.Replayed = true
+		return nil
+	}
+
+	if err = h.reuseOrNext(ctx, req, resp); err != nil {
+		// 释放幂等键，客户端可以使用相同的幂等键重试
+		if er := h.ic.Abandon(ctx, key); er != nil {
+			elog.DefaultLogger.Warn("释放幂等键失败", elog.FieldErr(er), elog.String("key", key))
+		}
+		return err
+	}
+
+	val, err = h.encode(req, resp)
+	if err == nil {
+		err = h.ic.Complete(ctx, key, val, h.ttl)
+	}
+	if err != nil {
+		// 短链已经生成成功，保存结果失败只会导致幂等键在过期前一直处于处理中
+		elog.DefaultLogger.Warn("保存幂等键结果失败", elog.FieldErr(err), elog.String("key", key))
+	}
+
+	return nil
+}
+
+// reuseOrNext 开启URL去重时先查询已有的短链，自定义短码是业务方指定的，不做去重
+func (h *IdempotentHandler) reuseOrNext(ctx context.Context, req *Request, resp *Response) error {
+	if !h.dedup || req.CustomCode != "" {
+		return h.next.Process(ctx, req, resp)
+	}
+
+	sc, err := h.repo.FindReusable(ctx, req.Biz, req.Creator, req.OriginURL, time.Now().UnixMilli())
+	switch {
+	case err == nil:
+		resp.ID, resp.OriginURL, resp.ShortCode, resp.ExpireAt = sc.ID, sc.OriginalURL, sc.ShortCode, sc.ExpireAt
+		resp.Replayed = true
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return h.next.Process(ctx, req, resp)
+	default:
+		return err
+	}
+}
+
+func (h *IdempotentHandler) encode(req *Request, resp *Response) (string, error) {
+	val, err := json.Marshal(idempotencyRecord{
+		URLResponse: domain.URLResponse{
+			ID:        resp.ID,
+			OriginURL: req.OriginURL,
+			ShortCode: resp.ShortCode,
+			ExpireAt:  resp.ExpireAt,
+		},
+		Biz:     req.Biz,
+		Creator: req.Creator,
+	})
+	return string(val), err
+}
+
+// idempotencyRecord 幂等键保存的结果，记录第一次请求的业务、创建者和原始URL，用于识别复用幂等键的不同请求
+type idempotencyRecord struct {
+	domain.URLResponse
+	Biz     string
+	Creator string
+}
+
+// idempotencyKey 幂等键只在同一业务的同一创建者范围内生效
+func idempotencyKey(req *Request) string {
+	return strings.Join([]string{req.Biz, req.Creator, req.IdempotencyKey}, ":")
+}
@@ -0,0 +1,228 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	intrv1 "github.com/TimeWtr/generator/api/proto/gen/intr.v1"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache/idempotent"
+	"github.com/TimeWtr/generator/repository/cache/mapping"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// generateHandler 模拟后续的生成流程，每次调用生成新的短码
+type generateHandler struct {
+	BaseHandler
+	calls int
+	err   error
+}
+
+func (g *generateHandler) Process(_ context.Context, _ *Request, resp *Response) error {
+	g.calls++
+	if g.err != nil {
+		return g.err
+	}
+
+	resp.ID = int64(g.calls)
+	resp.ShortCode = "gen" + strconv.Itoa(g.calls)
+	return nil
+}
+
+func TestIdempotentHandler_Key(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	mr, client := testutil.NewRedis(t)
+	ic := idempotent.NewCacheIdempotency(client)
+	ctx := context.Background()
+
+	h := NewIdempotentHandler(ic, repo, false, time.Hour)
+	next := &generateHandler{}
+	h.Next(next)
+	req := &Request{Biz: "marketing", Creator: "alice", OriginURL: "https://example.com", IdempotencyKey: "k1"}
+
+	// 第一次请求占用幂等键并保存结果
+	resp := &Response{}
+	require.NoError(t, h.Process(ctx, req, resp))
+	assert.Equal(t, "gen1", resp.ShortCode)
+
+	// 相同幂等键的请求直接返回第一次的结果
+	resp = &Response{}
+	require.NoError(t, h.Process(ctx, req, resp))
+	assert.Equal(t, int64(1), resp.ID)
+	assert.Equal(t, "gen1", resp.ShortCode)
+	assert.Equal(t, "https://example.com", resp.OriginURL)
+	assert.True(t, resp.Replayed)
+	assert.Equal(t, 1, next.calls)
+
+	// 相同幂等键携带不同的原始URL
+	err := h.Process(ctx, &Request{Biz: "marketing", Creator: "alice", OriginURL: "https://evil.com", IdempotencyKey: "k1"}, &Response{})
+	assert.ErrorIs(t, err, generator.ErrIdempotencyConflict)
+	assert.Equal(t, 1, next.calls)
+
+	// 幂等键只在同一创建者范围内生效
+	resp = &Response{}
+	require.NoError(t, h.Process(ctx, &Request{Biz: "marketing", Creator: "bob", IdempotencyKey: "k1"}, resp))
+	assert.Equal(t, "gen2", resp.ShortCode)
+
+	// 请求仍在处理中
+	_, acquired, err := ic.Acquire(ctx, "marketing:alice:k2", time.Hour)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	err = h.Process(ctx, &Request{Biz: "marketing", Creator: "alice", IdempotencyKey: "k2"}, &Response{})
+	assert.ErrorIs(t, err, generator.ErrRequestInProgress)
+
+	// 幂等键过期后重新生成
+	mr.FastForward(time.Hour)
+	resp = &Response{}
+	require.NoError(t, h.Process(ctx, req, resp))
+	assert.Equal(t, "gen3", resp.ShortCode)
+}
+
+func TestIdempotentHandler_Abandon(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	mr, client := testutil.NewRedis(t)
+	ic := idempotent.NewCacheIdempotency(client)
+	ctx := context.Background()
+
+	h := NewIdempotentHandler(ic, repo, false, time.Hour)
+	next := &generateHandler{err: errors.New("mock error")}
+	h.Next(next)
+	req := &Request{Biz: "marketing", Creator: "alice", IdempotencyKey: "k1"}
+
+	assert.EqualError(t, h.Process(ctx, req, &Response{}), "mock error")
+	assert.False(t, mr.Exists(cache.IdempotencyKeyPrefix+"marketing:alice:k1"))
+
+	// 生成失败后释放幂等键，客户端可以使用相同的幂等键重试
+	next.err = nil
+	resp := &Response{}
+	require.NoError(t, h.Process(ctx, req, resp))
+	assert.Equal(t, "gen2", resp.ShortCode)
+
+	// 已经保存结果的幂等键不能释放
+	require.NoError(t, ic.Abandon(ctx, "marketing:alice:k1"))
+	assert.True(t, mr.Exists(cache.IdempotencyKeyPrefix+"marketing:alice:k1"))
+}
+
+func TestIdempotentHandler_Dedup(t *testing.T) {
+	repo, d := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, d.BatchInsert(ctx, []domain.URLData{
+		{ID: 100, Biz: "marketing", Creator: "alice", OriginURL: "https://example.com/a", ShortCode: "exist"},
+		{ID: 101, Biz: "marketing", Creator: "alice", OriginURL: "https://example.com/b", ShortCode: "expired",
+			ExpireAt: now.Add(-time.Hour).UnixMilli()},
+	}))
+
+	testCases := []struct {
+		name      string
+		dedup     bool
+		req       *Request
+		wantCode  string
+		wantCalls int
+	}{
+		{
+			name:     "reuse",
+			dedup:    true,
+			req:      &Request{Biz: "marketing", Creator: "alice", OriginURL: "https://example.com/a"},
+			wantCode: "exist",
+		},
+		{
+			name:      "dedup disabled",
+			req:       &Request{Biz: "marketing", Creator: "alice", OriginURL: "https://example.com/a"},
+			wantCode:  "gen1",
+			wantCalls: 1,
+		},
+		{
+			name:      "other creator",
+			dedup:     true,
+			req:       &Request{Biz: "marketing", Creator: "bob", OriginURL: "https://example.com/a"},
+			wantCode:  "gen1",
+			wantCalls: 1,
+		},
+		{
+			name:      "expired",
+			dedup:     true,
+			req:       &Request{Biz: "marketing", Creator: "alice", OriginURL: "https://example.com/b"},
+			wantCode:  "gen1",
+			wantCalls: 1,
+		},
+		{
+			name:      "custom code",
+			dedup:     true,
+			req:       &Request{Biz: "marketing", Creator: "alice", OriginURL: "https://example.com/a", CustomCode: "vanity"},
+			wantCode:  "gen1",
+			wantCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewIdempotentHandler(nil, repo, tc.dedup, time.Hour)
+			next := &generateHandler{}
+			h.Next(next)
+
+			resp := &Response{OriginURL: tc.req.OriginURL}
+			require.NoError(t, h.Process(ctx, tc.req, resp))
+			assert.Equal(t, tc.wantCode, resp.ShortCode)
+			assert.Equal(t, tc.wantCalls, next.calls)
+			assert.Equal(t, tc.req.OriginURL, resp.OriginURL)
+			assert.Equal(t, tc.wantCalls == 0, resp.Replayed)
+		})
+	}
+}
+
+func TestService_GenerateURL_IdempotencyConflict(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	_, client := testutil.NewRedis(t)
+	mc := mapping.NewCacheMapping(client)
+	s := NewService(testutil.NewIDCh(t), repo, newMemCacher(), nil,
+		WithMappingCache(mc, time.Hour),
+		WithIdempotency(idempotent.NewCacheIdempotency(client), DefaultIdempotencyConfig()))
+	ctx := context.Background()
+
+	generate := func(url string) (domain.URLResponse, error) {
+		key := "k1"
+		return s.GenerateURL(ctx, &intrv1.URLRequest{
+			Biz:     "marketing",
+			Creator: "alice",
+			Meta:    &intrv1.Metadata{OriginalUrl: url, IdempotencyKey: &key},
+		})
+	}
+
+	first, err := generate("https://example.com")
+	require.NoError(t, err)
+
+	// 复用幂等键提交不同的URL被拒绝，已有短码的映射缓存不受影响
+	_, err = generate("https://evil.com")
+	assert.ErrorIs(t, err, generator.ErrIdempotencyConflict)
+	m, err := mc.Get(ctx, first.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", m.OriginalURL)
+
+	// 相同的请求返回第一次的结果
+	res, err := generate("https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, first, res)
+}
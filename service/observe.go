@@ -0,0 +1,153 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"time"
+
+	"github.com/gotomicro/ego/core/emetric"
+	"github.com/gotomicro/ego/core/etrace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+const metricNamespace = "generator"
+
+var (
+	// stageDuration 责任链中每个处理器自身的耗时，不包含后续处理器的耗时
+	stageDuration = emetric.HistogramVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "pipeline",
+		Name:      "stage_duration_seconds",
+		Help:      "短码生成责任链中各处理器的耗时",
+		Labels:    []string{"biz", "stage", "result"},
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}.Build()
+
+	// poolFallbackCounter 哈希短码冲突后从短码池中获取短码的次数
+	poolFallbackCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "pipeline",
+		Name:      "pool_fallback_total",
+		Help:      "哈希短码冲突后从短码池中获取短码的次数",
+		Labels:    []string{"biz"},
+	}.Build()
+
+	// rehashCounter 哈希短码冲突后加盐重新计算哈希的次数
+	rehashCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "pipeline",
+		Name:      "rehash_total",
+		Help:      "哈希短码冲突后加盐重新计算哈希的次数",
+		Labels:    []string{"biz"},
+	}.Build()
+
+	// compensationCounter 持久化失败后执行补偿的次数
+	compensationCounter = emetric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "pipeline",
+		Name:      "compensation_total",
+		Help:      "持久化失败后执行补偿的次数",
+		Labels:    []string{"biz", "result"},
+	}.Build()
+)
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// stageScopeKey 当前处理器的观测范围在context中的key
+type stageScopeKey struct{}
+
+// stageScope 单次请求在某个处理器上的观测范围
+type stageScope struct {
+	start time.Time
+	// 调用后续处理器之前已经累计的耗时
+	elapsed time.Duration
+	parent  trace.Span
+	span    trace.Span
+}
+
+// observedHandler 为处理器记录链路追踪和耗时监控，处理器返回时以自身返回的错误结束观测，
+// 耗时不包含后续处理器的执行时间，后续处理器的span以当前处理器的上级span为父span，各处理器的span是同级的
+type observedHandler struct {
+	stage  string
+	biz    string
+	inner  Handler
+	next   Handler
+	tracer *etrace.Tracer
+}
+
+func newObservedHandler(stage string, biz string, inner Handler) Handler {
+	return &observedHandler{
+		stage:  stage,
+		biz:    biz,
+		inner:  inner,
+		tracer: etrace.NewTracer(trace.SpanKindInternal),
+	}
+}
+
+func (o *observedHandler) Next(h Handler) {
+	o.next = h
+	o.inner.Next(&stageBoundary{o: o})
+}
+
+func (o *observedHandler) Process(ctx context.Context, req *Request, resp *Response) error {
+	sc := &stageScope{
+		start:  time.Now(),
+		parent: trace.SpanFromContext(ctx),
+	}
+	ctx, sc.span = o.tracer.Start(ctx, "generator.pipeline."+o.stage, nil,
+		trace.WithAttributes(etrace.String("biz", o.biz), etrace.String("stage", o.stage)))
+
+	err := o.inner.Process(context.WithValue(ctx, stageScopeKey{}, sc), req, resp)
+	o.finish(sc, err)
+	return err
+}
+
+// finish 以处理器返回的错误结束观测，后续处理器失败时当前处理器透传的错误也记为失败
+func (o *observedHandler) finish(sc *stageScope, err error) {
+	elapsed := sc.elapsed + time.Since(sc.start)
+	stageDuration.Observe(elapsed.Seconds(), o.biz, o.stage, resultLabel(err))
+	if err != nil {
+		sc.span.RecordError(err)
+		sc.span.SetStatus(codes.Error, err.Error())
+	}
+	sc.span.End()
+}
+
+// stageBoundary 被观测的处理器调用后续处理器时暂停计时，后续处理器使用以上级span为父span的context，
+// 后续处理器返回后继续计时，当前处理器返回时才结束观测
+type stageBoundary struct {
+	o *observedHandler
+}
+
+func (b *stageBoundary) Next(Handler) {}
+
+func (b *stageBoundary) Process(ctx context.Context, req *Request, resp *Response) error {
+	sc, ok := ctx.Value(stageScopeKey{}).(*stageScope)
+	if !ok {
+		return b.o.next.Process(ctx, req, resp)
+	}
+
+	sc.elapsed += time.Since(sc.start)
+	err := b.o.next.Process(trace.ContextWithSpan(ctx, sc.parent), req, resp)
+	sc.start = time.Now()
+	return err
+}
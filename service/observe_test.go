@@ -0,0 +1,87 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// persistHandler 先调用后续处理器，返回后再执行持久化，模拟DBHandler
+type persistHandler struct {
+	BaseHandler
+	err error
+}
+
+func (p *persistHandler) Process(ctx context.Context, req *Request, resp *Response) error {
+	if err := p.next.Process(ctx, req, resp); err != nil {
+		return err
+	}
+	return p.err
+}
+
+// stageCount 处理器在指定结果下的观测次数
+func stageCount(t *testing.T, biz string, stage string, result string) uint64 {
+	var m dto.Metric
+	require.NoError(t, stageDuration.WithLabelValues(biz, stage, result).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestObservedHandler_Result(t *testing.T) {
+	testCases := []struct {
+		name       string
+		biz        string
+		err        error
+		wantResult string
+	}{
+		{
+			name:       "ok",
+			biz:        "observe-ok",
+			wantResult: "ok",
+		},
+		{
+			name:       "failed after next",
+			biz:        "observe-failed",
+			err:        errors.New("mock db error"),
+			wantResult: "error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newObservedHandler("db", tc.biz, &persistHandler{err: tc.err})
+			next := &recordHandler{}
+			h.Next(newObservedHandler("tail", tc.biz, next))
+
+			// 指标是全局注册的，只比较本次执行前后的差值
+			result, ok, failed, tail := stageCount(t, tc.biz, "db", tc.wantResult), stageCount(t, tc.biz, "db", "ok"),
+				stageCount(t, tc.biz, "db", "error"), stageCount(t, tc.biz, "tail", "ok")
+			err := h.Process(context.Background(), &Request{Biz: tc.biz}, &Response{})
+			assert.Equal(t, tc.err, err)
+			assert.True(t, next.called)
+
+			// 后续处理器返回之后的失败也要记录在当前处理器上
+			assert.Equal(t, result+1, stageCount(t, tc.biz, "db", tc.wantResult))
+			assert.Equal(t, ok+failed+1, stageCount(t, tc.biz, "db", "ok")+stageCount(t, tc.biz, "db", "error"))
+			assert.Equal(t, tail+1, stageCount(t, tc.biz, "tail", "ok"))
+		})
+	}
+}
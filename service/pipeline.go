@@ -0,0 +1,182 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// 内置处理器的名称
+const (
+	StageIdempotent = "idempotent"
+	StageID         = "id"
+	StageCustomCode = "custom_code"
+	StageHash       = "hash"
+	StageShortCode  = "short_code"
+	StageDB         = "db"
+	StageCompensate = "compensate"
+)
+
+// DefaultStages 默认的短码生成流程，补偿处理器必须紧跟在数据库处理器之后，只在持久化失败时执行
+var DefaultStages = []string{StageIdempotent, StageID, StageCustomCode, StageHash, StageShortCode, StageDB, StageCompensate}
+
+// HandlerFactory 为指定业务创建处理器，处理器在责任链中会被并发调用，不能保存单次请求的状态
+type HandlerFactory func(biz string) Handler
+
+// PipelineConfig 责任链的配置
+type PipelineConfig struct {
+	// 默认的处理器顺序
+	Stages []string
+	// 业务自定义的处理器顺序，没有配置的业务使用默认顺序
+	Biz map[string][]string
+}
+
+func DefaultPipelineConfig() PipelineConfig {
+	return PipelineConfig{
+		Stages: slices.Clone(DefaultStages),
+	}
+}
+
+// Pipeline 短码生成责任链的构建器，按照配置的顺序将注册的处理器串联起来，
+// 每个业务的责任链只在第一次使用时构建一次，之后所有请求复用。只有配置了处理器顺序或者通过Declare
+// 声明的业务单独构建责任链，其他业务共用一条默认责任链，客户端传递任意业务不会导致缓存无限增长
+type Pipeline struct {
+	cfg PipelineConfig
+	// 处理器名称到工厂的映射
+	factories map[string]HandlerFactory
+	// 声明单独构建责任链的业务
+	declared map[string]bool
+	mu       sync.RWMutex
+	// 已经构建好的责任链，key是业务，默认责任链的key为空
+	chains map[string]Handler
+}
+
+func NewPipeline(cfg PipelineConfig) *Pipeline {
+	return &Pipeline{
+		cfg:       cfg,
+		factories: make(map[string]HandlerFactory),
+		declared:  make(map[string]bool),
+		chains:    make(map[string]Handler),
+	}
+}
+
+// Declare 声明单独构建责任链的业务，处理器工厂需要按照业务创建不同的处理器时使用，比如开启URL去重的业务
+func (p *Pipeline) Declare(biz ...string) *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, b := range biz {
+		p.declared[b] = true
+	}
+	p.chains = make(map[string]Handler)
+	return p
+}
+
+// Register 注册处理器，名称已经存在时替换原有的处理器，可以用来在测试中替换任意一个环节
+func (p *Pipeline) Register(name string, factory HandlerFactory) *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.factories[name] = factory
+	p.chains = make(map[string]Handler)
+	return p
+}
+
+// InsertBefore 在默认顺序中target之前插入处理器
+func (p *Pipeline) InsertBefore(target string, name string) error {
+	return p.insert(target, name, 0)
+}
+
+// InsertAfter 在默认顺序中target之后插入处理器
+func (p *Pipeline) InsertAfter(target string, name string) error {
+	return p.insert(target, name, 1)
+}
+
+func (p *Pipeline) insert(target string, name string, offset int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idx := slices.Index(p.cfg.Stages, target)
+	if idx < 0 {
+		return fmt.Errorf("处理器%s不存在", target)
+	}
+
+	p.cfg.Stages = slices.Insert(slices.Clone(p.cfg.Stages), idx+offset, name)
+	p.chains = make(map[string]Handler)
+	return nil
+}
+
+// Chain 获取业务的责任链入口
+func (p *Pipeline) Chain(biz string) (Handler, error) {
+	p.mu.RLock()
+	h, ok := p.chains[p.key(biz)]
+	p.mu.RUnlock()
+	if ok {
+		return h, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.key(biz)
+	if h, ok = p.chains[key]; ok {
+		return h, nil
+	}
+
+	stages, ok := p.cfg.Biz[biz]
+	if !ok {
+		stages = p.cfg.Stages
+	}
+
+	h, err := p.build(key, stages)
+	if err != nil {
+		return nil, err
+	}
+
+	p.chains[key] = h
+	return h, nil
+}
+
+// key 责任链缓存的key，没有配置处理器顺序也没有声明的业务使用默认责任链
+func (p *Pipeline) key(biz string) string {
+	if _, ok := p.cfg.Biz[biz]; ok || p.declared[biz] {
+		return biz
+	}
+	return ""
+}
+
+// build 按照顺序创建处理器并串联，每个处理器都会记录链路追踪和耗时监控
+func (p *Pipeline) build(biz string, stages []string) (Handler, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("业务%s没有配置处理器", biz)
+	}
+
+	handlers := make([]Handler, len(stages))
+	for i, name := range stages {
+		factory, ok := p.factories[name]
+		if !ok {
+			return nil, fmt.Errorf("处理器%s未注册", name)
+		}
+		handlers[i] = newObservedHandler(name, biz, factory(biz))
+	}
+
+	for i := 0; i < len(handlers)-1; i++ {
+		handlers[i].Next(handlers[i+1])
+	}
+
+	return handlers[0], nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// stageHandler 记录处理器执行顺序的处理器
+type stageHandler struct {
+	BaseHandler
+	name  string
+	trace *[]string
+}
+
+func (s *stageHandler) Process(ctx context.Context, req *Request, resp *Response) error {
+	*s.trace = append(*s.trace, s.name)
+	if s.next == nil {
+		return nil
+	}
+	return s.next.Process(ctx, req, resp)
+}
+
+// stageFactory 创建名称为name的处理器，执行时记录到trace中
+func stageFactory(name string, trace *[]string) HandlerFactory {
+	return func(string) Handler {
+		return &stageHandler{name: name, trace: trace}
+	}
+}
+
+func TestPipeline_Chain(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     PipelineConfig
+		biz     string
+		setup   func(p *Pipeline, trace *[]string) error
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "default stages",
+			cfg:  PipelineConfig{Stages: []string{"a", "b", "c"}},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "replace stage",
+			cfg:  PipelineConfig{Stages: []string{"a", "b", "c"}},
+			setup: func(p *Pipeline, trace *[]string) error {
+				p.Register("b", stageFactory("mock_b", trace))
+				return nil
+			},
+			want: []string{"a", "mock_b", "c"},
+		},
+		{
+			name: "insert before",
+			cfg:  PipelineConfig{Stages: []string{"a", "b", "c"}},
+			setup: func(p *Pipeline, trace *[]string) error {
+				p.Register("x", stageFactory("x", trace))
+				return p.InsertBefore("a", "x")
+			},
+			want: []string{"x", "a", "b", "c"},
+		},
+		{
+			name: "insert after",
+			cfg:  PipelineConfig{Stages: []string{"a", "b", "c"}},
+			setup: func(p *Pipeline, trace *[]string) error {
+				p.Register("x", stageFactory("x", trace))
+				return p.InsertAfter("c", "x")
+			},
+			want: []string{"a", "b", "c", "x"},
+		},
+		{
+			name: "biz stages",
+			cfg: PipelineConfig{
+				Stages: []string{"a", "b", "c"},
+				Biz:    map[string][]string{"marketing": {"c", "a"}},
+			},
+			biz:  "marketing",
+			want: []string{"c", "a"},
+		},
+		{
+			name: "insert only changes default stages",
+			cfg: PipelineConfig{
+				Stages: []string{"a", "b", "c"},
+				Biz:    map[string][]string{"marketing": {"c", "a"}},
+			},
+			biz: "marketing",
+			setup: func(p *Pipeline, trace *[]string) error {
+				p.Register("x", stageFactory("x", trace))
+				return p.InsertAfter("a", "x")
+			},
+			want: []string{"c", "a"},
+		},
+		{
+			name: "insert before unknown stage",
+			cfg:  PipelineConfig{Stages: []string{"a", "b", "c"}},
+			setup: func(p *Pipeline, trace *[]string) error {
+				return p.InsertBefore("unknown", "x")
+			},
+			wantErr: "处理器unknown不存在",
+		},
+		{
+			name: "insert after unknown stage",
+			cfg:  PipelineConfig{Stages: []string{"a", "b", "c"}},
+			setup: func(p *Pipeline, trace *[]string) error {
+				return p.InsertAfter("unknown", "x")
+			},
+			wantErr: "处理器unknown不存在",
+		},
+		{
+			name:    "unregistered stage",
+			cfg:     PipelineConfig{Stages: []string{"a", "unknown"}},
+			wantErr: "处理器unknown未注册",
+		},
+		{
+			name:    "empty stages",
+			cfg:     PipelineConfig{Biz: map[string][]string{"marketing": {}}},
+			biz:     "marketing",
+			wantErr: "业务marketing没有配置处理器",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var trace []string
+			p := NewPipeline(tc.cfg)
+			for _, name := range []string{"a", "b", "c"} {
+				p.Register(name, stageFactory(name, &trace))
+			}
+
+			if tc.setup != nil {
+				if err := tc.setup(p, &trace); err != nil {
+					assert.EqualError(t, err, tc.wantErr)
+					return
+				}
+			}
+
+			h, err := p.Chain(tc.biz)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			require.NoError(t, h.Process(context.Background(), &Request{Biz: tc.biz}, &Response{}))
+			assert.Equal(t, tc.want, trace)
+		})
+	}
+}
+
+func TestPipeline_ChainCache(t *testing.T) {
+	var trace []string
+	builds := map[string]int{}
+	p := NewPipeline(PipelineConfig{
+		Stages: []string{"a"},
+		Biz:    map[string][]string{"marketing": {"a"}},
+	}).
+		Declare("dedup").
+		Register("a", func(biz string) Handler {
+			builds[biz]++
+			return &stageHandler{name: "a", trace: &trace}
+		})
+
+	h1, err := p.Chain("marketing")
+	require.NoError(t, err)
+	h2, err := p.Chain("marketing")
+	require.NoError(t, err)
+	assert.Same(t, h1, h2)
+
+	_, err = p.Chain("dedup")
+	require.NoError(t, err)
+
+	// 没有配置的业务共用默认责任链
+	other, err := p.Chain("other")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		h, er := p.Chain(fmt.Sprintf("unknown%d", i))
+		require.NoError(t, er)
+		assert.Same(t, other, h)
+	}
+	assert.Equal(t, map[string]int{"marketing": 1, "dedup": 1, "": 1}, builds)
+	assert.Len(t, p.chains, 3)
+
+	// 注册和插入处理器后重新构建
+	p.Register("b", stageFactory("b", &trace))
+	h3, err := p.Chain("other")
+	require.NoError(t, err)
+	assert.NotSame(t, other, h3)
+
+	require.NoError(t, p.InsertAfter("a", "b"))
+	h4, err := p.Chain("other")
+	require.NoError(t, err)
+	assert.NotSame(t, h3, h4)
+	assert.Equal(t, map[string]int{"marketing": 1, "dedup": 1, "": 3}, builds)
+
+	require.NoError(t, h4.Process(context.Background(), &Request{Biz: "other"}, &Response{}))
+	assert.Equal(t, []string{"a", "b"}, trace)
+}
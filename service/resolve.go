@@ -0,0 +1,139 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	intrv1 "github.com/TimeWtr/generator/api/proto/gen/intr.v1"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultMappingTTL 短码映射的默认缓存时间，短链的剩余有效期更短时使用剩余有效期
+	DefaultMappingTTL = time.Hour
+	// missingMappingTTL 不存在的短码的缓存时间，防止不存在的短码反复穿透到数据库
+	missingMappingTTL = time.Minute
+)
+
+// WithMappingCache 开启短码映射的缓存，生成时写入缓存，解析时优先读取缓存，修改和删除后清理缓存
+func WithMappingCache(mc cache.MappingCache, ttl time.Duration) Option {
+	return func(s *Service) {
+		s.mc = mc
+		s.mappingTTL = ttl
+	}
+}
+
+// ResolveShortCode 解析短码对应的原始URL，先查询映射缓存，缓存不存在时查询短码所在的分片并回填缓存，
+// 不存在的短码同样会缓存一段时间，已经过期的短码返回ErrURLExpired。分片由请求的业务路由，
+// 不存在的短码只对查询时的业务生效，指定业务时短码不属于该业务同样返回ErrURLNotFound
+func (s *Service) ResolveShortCode(ctx context.Context, req *intrv1.ResolveRequest) (domain.URLData, error) {
+	code, biz := req.GetShortCode(), req.GetBiz()
+	if s.mc != nil {
+		m, err := s.mc.Get(ctx, code)
+		switch {
+		case err == nil && m.OriginalURL == "" && m.Biz != biz:
+			// 其他业务路由到的分片中不存在，不代表当前业务路由到的分片中也不存在
+		case err == nil:
+			if m.OriginalURL == "" || !belongsTo(m.Biz, biz) {
+				return domain.URLData{}, generator.ErrURLNotFound
+			}
+			return s.checkExpired(domain.URLData{
+				ID:        m.ID,
+				Biz:       m.Biz,
+				OriginURL: m.OriginalURL,
+				ShortCode: code,
+				ExpireAt:  m.ExpireAt,
+			})
+		case !errors.Is(err, cache.ErrMappingMiss):
+			// 缓存不可用时降级查询数据库
+			s.el.Warn("查询短码映射缓存失败", elog.FieldErr(err), elog.String("shortCode", code))
+		}
+	}
+
+	d, err := s.repo.Shard(biz, code)
+	if err != nil {
+		return domain.URLData{}, err
+	}
+
+	sc, err := d.GetURLByShortCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.cacheMapping(ctx, code, cache.Mapping{Biz: biz})
+			return domain.URLData{}, generator.ErrURLNotFound
+		}
+		return domain.URLData{}, err
+	}
+
+	s.cacheMapping(ctx, code, cache.Mapping{
+		ID:          sc.ID,
+		Biz:         sc.Biz,
+		OriginalURL: sc.OriginalURL,
+		ExpireAt:    sc.ExpireAt,
+	})
+	if !belongsTo(sc.Biz, biz) {
+		return domain.URLData{}, generator.ErrURLNotFound
+	}
+	return s.checkExpired(toURLData(sc))
+}
+
+// belongsTo 短链是否属于请求的业务，请求没有指定业务时不限制
+func belongsTo(owner string, biz string) bool {
+	return biz == "" || owner == biz
+}
+
+func (s *Service) checkExpired(data domain.URLData) (domain.URLData, error) {
+	if domain.Expired(data.ExpireAt, time.Now().UnixMilli()) {
+		return domain.URLData{}, generator.ErrURLExpired
+	}
+
+	return data, nil
+}
+
+// cacheMapping 写入短码映射的缓存，缓存时间不超过短链的剩余有效期，已经过期的短链同样缓存，
+// 避免过期短码的请求全部落到数据库。不存在的短码只记录查询时的业务。缓存失败不影响主流程
+func (s *Service) cacheMapping(ctx context.Context, code string, m cache.Mapping) {
+	if s.mc == nil {
+		return
+	}
+
+	ttl := s.mappingTTL
+	if m.OriginalURL == "" {
+		ttl = missingMappingTTL
+	} else if remain := time.Until(time.UnixMilli(m.ExpireAt)); remain > 0 && remain < ttl {
+		ttl = remain
+	}
+
+	if err := s.mc.Set(ctx, code, m, ttl); err != nil {
+		s.el.Warn("写入短码映射缓存失败", elog.FieldErr(err), elog.String("shortCode", code))
+	}
+}
+
+// evictMapping 短链修改或删除后清理映射缓存，清理失败时缓存在过期后自然失效
+func (s *Service) evictMapping(ctx context.Context, code string) {
+	if s.mc == nil {
+		return
+	}
+
+	if err := s.mc.Del(ctx, code); err != nil {
+		s.el.Error("清理短码映射缓存失败", elog.FieldErr(err), elog.String("shortCode", code))
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	intrv1 "github.com/TimeWtr/generator/api/proto/gen/intr.v1"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository/cache"
+	"github.com/TimeWtr/generator/repository/cache/mapping"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestService_ResolveShortCode(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	_, client := testutil.NewRedis(t)
+	mc := mapping.NewCacheMapping(client)
+	s := NewService(testutil.NewIDCh(t), repo, nil, nil, WithMappingCache(mc, time.Hour))
+	ctx := context.Background()
+
+	resolve := func(biz string) error {
+		_, err := s.ResolveShortCode(ctx, &intrv1.ResolveRequest{ShortCode: "abc", Biz: biz})
+		return err
+	}
+
+	// 不存在的短码缓存查询时的业务
+	assert.ErrorIs(t, resolve("other"), generator.ErrURLNotFound)
+	m, err := mc.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, cache.Mapping{Biz: "other"}, m)
+
+	// 写入短码后清理不存在的缓存
+	h := NewDBHandler(repo, testutil.NewIDCh(t), mc)
+	h.Next(&recordHandler{})
+	require.NoError(t, h.Process(ctx, &Request{Biz: "marketing", OriginURL: "https://example.com"}, &Response{ShortCode: "abc"}))
+	_, err = mc.Get(ctx, "abc")
+	assert.ErrorIs(t, err, cache.ErrMappingMiss)
+
+	// 其他业务缓存的不存在记录不影响当前业务的查询
+	require.NoError(t, mc.Set(ctx, "abc", cache.Mapping{Biz: "other"}, time.Hour))
+	assert.NoError(t, resolve("marketing"))
+
+	// 缓存命中时同样校验所属业务
+	m, err = mc.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "marketing", m.Biz)
+	assert.ErrorIs(t, resolve("other"), generator.ErrURLNotFound)
+	assert.NoError(t, resolve(""))
+}
@@ -15,14 +15,22 @@
 package service
 
 import (
+	"cmp"
+	"encoding/json"
 	"errors"
 	"github.com/TimeWtr/generator"
+	"github.com/TimeWtr/generator/event"
+	"github.com/gotomicro/ego/core/elog"
 	"github.com/panjf2000/ants/v2"
 	"gorm.io/gorm"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/TimeWtr/generator/repository"
 	"github.com/TimeWtr/generator/repository/cache"
 	"github.com/TimeWtr/generator/repository/dao"
 	lmt "github.com/TimeWtr/local_message_table"
@@ -37,61 +45,247 @@ import (
 type URLServiceInter interface {
 	// GenerateURL 生成单条URL
 	GenerateURL(ctx context.Context, req *intrv1.URLRequest) (domain.URLResponse, error)
-	// BatchGenerateURL 批量生成URL
-	BatchGenerateURL(ctx context.Context, req *intrv1.BatchURLRequest) ([]domain.URLResponse, error)
+	// BatchGenerateURL 批量生成URL，返回结果与请求中meta的顺序一致
+	BatchGenerateURL(ctx context.Context, req *intrv1.BatchURLRequest) ([]domain.BatchURLResult, error)
+	// UpdateURL 修改短链的原始URL、备注或过期时间
+	UpdateURL(ctx context.Context, req *intrv1.UpdateURLRequest) (domain.URLResponse, error)
+	// DeleteURL 删除单条短链
+	DeleteURL(ctx context.Context, req *intrv1.DelRequest) error
+	// LookupByURL 查询所有分片中指向原始URL的短链
+	LookupByURL(ctx context.Context, req *intrv1.LookupByURLRequest) ([]domain.URLData, error)
+	// ResolveShortCode 解析短码对应的原始URL
+	ResolveShortCode(ctx context.Context, req *intrv1.ResolveRequest) (domain.URLData, error)
 }
 
-const RetryCounts = 5
-
 type Service struct {
 	// ID获取的通道
 	idCh <-chan int64
-	// 数据库层操作
-	d dao.ShortCodeInter
+	// 分库分表的短码数据操作
+	repo repository.GeneratorRepository
 	// 缓存层操作
 	cc cache.Cacher
-	// 本地消息表
-	lt lmt.MessagePusher
 	// 全局的goroutine任务池
 	pool *ants.Pool
+	// 已删除短码的隔离区，为nil时删除的短码不回收
+	rc cache.RecycleCache
+	// 删除的短码在隔离区中的停留时间
+	quarantine time.Duration
+	// 自定义短码的校验规则
+	customCodeRule CustomCodeRule
+	// 哈希短码冲突的处理策略
+	collision CollisionPolicy
+	// 短码映射的缓存，为nil时不缓存
+	mc cache.MappingCache
+	// 短码映射的缓存时间
+	mappingTTL time.Duration
+	// 过期策略
+	expiration ExpirationConfig
+	// 客户端幂等键的存储，为nil时忽略请求中的幂等键
+	ic cache.IdempotencyCache
+	// 幂等生成的配置
+	idempotency IdempotencyConfig
+	// 短码生成的责任链
+	pipeline *Pipeline
+	// 责任链的配置
+	pipelineCfg PipelineConfig
+	// 对默认责任链的定制，比如注册自定义处理器、替换内置处理器
+	customizers []func(p *Pipeline)
+	// 日志
+	el *elog.Component
 }
 
-func NewService(idCh <-chan int64, d dao.ShortCodeInter,
-	cc cache.Cacher, lt lmt.MessagePusher, pool *ants.Pool) URLServiceInter {
-	return &Service{
-		idCh: idCh,
-		d:    d,
-		cc:   cc,
-		lt:   lt,
-		pool: pool,
+type Option func(s *Service)
+
+// WithRecycle 开启已删除短码的回收，短码在隔离区停留quarantine后由回收任务归还到短码池
+func WithRecycle(rc cache.RecycleCache, quarantine time.Duration) Option {
+	return func(s *Service) {
+		s.rc = rc
+		s.quarantine = quarantine
+	}
+}
+
+// WithCustomCodeRule 替换默认的自定义短码校验规则
+func WithCustomCodeRule(rule CustomCodeRule) Option {
+	return func(s *Service) {
+		s.customCodeRule = rule
+	}
+}
+
+// WithCollisionPolicy 替换默认的哈希短码冲突处理策略
+func WithCollisionPolicy(policy CollisionPolicy) Option {
+	return func(s *Service) {
+		s.collision = policy
+	}
+}
+
+// WithIdempotency 开启幂等生成，ic用于保存客户端幂等键，cfg配置开启URL去重的业务
+func WithIdempotency(ic cache.IdempotencyCache, cfg IdempotencyConfig) Option {
+	return func(s *Service) {
+		s.ic = ic
+		s.idempotency = cfg
+	}
+}
+
+// WithPipelineConfig 配置默认和业务自定义的处理器顺序
+func WithPipelineConfig(cfg PipelineConfig) Option {
+	return func(s *Service) {
+		s.pipelineCfg = cfg
 	}
 }
 
+// WithPipeline 定制责任链，在内置处理器注册完成之后执行，可以注册新的处理器或者替换内置处理器
+func WithPipeline(fn func(p *Pipeline)) Option {
+	return func(s *Service) {
+		s.customizers = append(s.customizers, fn)
+	}
+}
+
+func NewService(idCh <-chan int64, repo repository.GeneratorRepository,
+	cc cache.Cacher, pool *ants.Pool, opts ...Option) URLServiceInter {
+	s := &Service{
+		idCh:           idCh,
+		repo:           repo,
+		cc:             cc,
+		pool:           pool,
+		customCodeRule: DefaultCustomCodeRule(),
+		collision:      DefaultCollisionPolicy(),
+		idempotency:    DefaultIdempotencyConfig(),
+		mappingTTL:     DefaultMappingTTL,
+		expiration:     DefaultExpirationConfig(),
+		pipelineCfg:    DefaultPipelineConfig(),
+		el:             elog.DefaultLogger,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.pipeline = s.newPipeline()
+	return s
+}
+
+// newPipeline 注册内置的处理器，再执行调用方的定制
+func (s *Service) newPipeline() *Pipeline {
+	p := NewPipeline(s.pipelineCfg).
+		Declare(slices.Collect(maps.Keys(s.idempotency.DedupBiz))...).
+		Register(StageIdempotent, func(biz string) Handler {
+			return NewIdempotentHandler(s.ic, s.repo, s.idempotency.DedupBiz[biz], s.idempotency.KeyTTL)
+		}).
+		Register(StageID, func(string) Handler {
+			return NewIDHandler(s.idCh)
+		}).
+		Register(StageCustomCode, func(string) Handler {
+			return NewCustomCodeHandler(s.cc, s.repo, s.customCodeRule)
+		}).
+		Register(StageHash, func(string) Handler {
+			return NewHashHandler(hs.NewMurmur3())
+		}).
+		Register(StageShortCode, func(string) Handler {
+			return NewShortCodeHandler(s.cc, hs.NewMurmur3(), s.collision)
+		}).
+		Register(StageDB, func(string) Handler {
+			return NewDBHandler(s.repo, s.idCh, s.mc)
+		}).
+		Register(StageCompensate, func(string) Handler {
+			return NewCompensateHandler(s.cc)
+		})
+
+	for _, fn := range s.customizers {
+		fn(p)
+	}
+
+	return p
+}
+
 func (s *Service) GenerateURL(ctx context.Context, req *intrv1.URLRequest) (domain.URLResponse, error) {
-	idHandler := NewIDHandler(s.idCh)
-	hashHandler := NewHashHandler(hs.NewMurmur3())
-	scHandler := NewShortCodeHandler(s.cc)
-	dbHandler := NewDBHandler(s.lt, s.idCh)
-	cmHandler := NewCompensateHandler(s.cc)
-	idHandler.Next(hashHandler)
-	hashHandler.Next(scHandler)
-	scHandler.Next(dbHandler)
-	dbHandler.Next(cmHandler)
-
-	request := &Request{
-		Biz:        req.GetBiz(),
-		OriginURL:  req.GetMeta().GetOriginalUrl(),
-		Creator:    req.GetCreator(),
-		Comment:    req.GetMeta().GetComment(),
-		Expiration: int(req.GetMeta().GetExpiration()),
-		CustomCode: req.GetMeta().GetCustomCode(),
-	}
-	response := &Response{}
-	err := idHandler.Process(ctx, request, response)
+	request, err := s.newRequest(req.GetBiz(), req.GetCreator(), req.GetMeta())
 	if err != nil {
 		return domain.URLResponse{}, err
 	}
 
+	return s.generate(ctx, request)
+}
+
+// newRequest 根据请求元数据构建责任链的请求，过期时间按照业务的过期策略在这里计算
+func (s *Service) newRequest(biz string, creator string, meta *intrv1.Metadata) (*Request, error) {
+	expiry, err := expiryFromMeta(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	expireAt, err := s.expiration.policy(biz).ExpireAt(expiry, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Request{
+		Biz:            biz,
+		OriginURL:      meta.GetOriginalUrl(),
+		Creator:        creator,
+		Comment:        meta.GetComment(),
+		ExpireAt:       expireAt,
+		CustomCode:     meta.GetCustomCode(),
+		IdempotencyKey: meta.GetIdempotencyKey(),
+	}, nil
+}
+
+// BatchGenerateURL 每条URL作为一个任务提交到任务池中并发执行，等待所有任务结束后汇总每条URL的结果，
+// 单条失败不影响其他URL的生成，ctx取消后尚未提交的URL直接记录为失败
+func (s *Service) BatchGenerateURL(ctx context.Context, req *intrv1.BatchURLRequest) ([]domain.BatchURLResult, error) {
+	metas := req.GetMeta()
+	results := make([]domain.BatchURLResult, len(metas))
+
+	var wg sync.WaitGroup
+	for i, r := range metas {
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		request, err := s.newRequest(req.GetBiz(), req.GetCreator(), r)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		wg.Add(1)
+		err = s.pool.Submit(func() {
+			defer wg.Done()
+			res, er := s.generate(ctx, request)
+			results[i] = domain.BatchURLResult{URLResponse: res, Err: er}
+		})
+		if err != nil {
+			wg.Done()
+			results[i].Err = err
+		}
+	}
+
+	wg.Wait()
+	return results, nil
+}
+
+// generate 获取业务对应的责任链并执行
+func (s *Service) generate(ctx context.Context, request *Request) (domain.URLResponse, error) {
+	chain, err := s.pipeline.Chain(request.Biz)
+	if err != nil {
+		return domain.URLResponse{}, err
+	}
+
+	response := &Response{OriginURL: request.OriginURL}
+	err = chain.Process(ctx, request, response)
+	if err != nil {
+		return domain.URLResponse{}, err
+	}
+
+	if !response.Replayed {
+		s.cacheMapping(ctx, response.ShortCode, cache.Mapping{
+			ID:          response.ID,
+			Biz:         request.Biz,
+			OriginalURL: response.OriginURL,
+			ExpireAt:    response.ExpireAt,
+		})
+	}
+
 	return domain.URLResponse{
 		ID:        response.ID,
 		OriginURL: response.OriginURL,
@@ -100,56 +294,256 @@ func (s *Service) GenerateURL(ctx context.Context, req *intrv1.URLRequest) (doma
 	}, nil
 }
 
-func (s *Service) BatchGenerateURL(ctx context.Context, req *intrv1.BatchURLRequest) ([]domain.URLResponse, error) {
-	for _, r := range req.GetMeta() {
-		request := &Request{
-			Biz:        req.GetBiz(),
-			OriginURL:  r.GetOriginalUrl(),
-			Creator:    req.GetCreator(),
-			Comment:    r.GetComment(),
-			Expiration: int(r.GetExpiration()),
-			CustomCode: r.GetCustomCode(),
+// UpdateURL 修改已有短链的原始URL、备注或过期时间，未传递的字段保持不变，只有短链所属的业务才能修改。
+// 修改和变更消息在同一个本地消息表事务中完成，跳转服务消费消息后清理短码的缓存映射，避免修改后仍然跳转到旧的URL
+func (s *Service) UpdateURL(ctx context.Context, req *intrv1.UpdateURLRequest) (domain.URLResponse, error) {
+	sc, err := s.repo.GetByID(ctx, req.GetId())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.URLResponse{}, generator.ErrURLNotFound
 		}
+		return domain.URLResponse{}, err
+	}
 
-		s.pool.Submit(func() {
-			idHandler := NewIDHandler(s.idCh)
-			hashHandler := NewHashHandler(hs.NewMurmur3())
-			scHandler := NewShortCodeHandler(s.cc)
-			dbHandler := NewDBHandler(s.lt, s.idCh)
-			cmHandler := NewCompensateHandler(s.cc)
-			idHandler.Next(hashHandler)
-			hashHandler.Next(scHandler)
-			scHandler.Next(dbHandler)
-			dbHandler.Next(cmHandler)
-
-			response := &Response{}
-			err := idHandler.Process(ctx, request, response)
-			if err != nil {
-				// 处理失败了
+	if sc.Biz != req.GetBiz() {
+		return domain.URLResponse{}, generator.ErrURLForbidden
+	}
+
+	data := domain.URLData{
+		ID:        sc.ID,
+		OriginURL: req.GetMeta().GetOriginalUrl(),
+		Comment:   req.GetMeta().GetComment(),
+	}
+	expiry, err := expiryFromMeta(req.GetMeta())
+	if err != nil {
+		return domain.URLResponse{}, err
+	}
+
+	// 未指定过期方式时保持原有的过期时间
+	var neverExpire bool
+	if expiry.Kind != domain.ExpiryDefault {
+		data.ExpireAt, err = s.expiration.policy(sc.Biz).ExpireAt(expiry, time.Now())
+		if err != nil {
+			return domain.URLResponse{}, err
+		}
+		sc.ExpireAt = data.ExpireAt
+		neverExpire = data.ExpireAt == domain.NeverExpire
+	}
+
+	if data.OriginURL != "" {
+		sc.OriginalURL = data.OriginURL
+	}
+
+	fn := func(ctx context.Context, d dao.ShortCodeInter) ([]lmt.Messages, error) {
+		er := d.Update(ctx, data)
+		if er != nil {
+			return nil, er
+		}
+
+		// 过期时间为0时Update不会修改，需要单独清除
+		if neverExpire {
+			if er = d.ClearExpiration(ctx, sc.ID); er != nil {
+				return nil, er
 			}
+		}
+
+		id, er := s.getID(ctx)
+		if er != nil {
+			return nil, er
+		}
+
+		content, er := json.Marshal(event.URLEvent{
+			Type:        event.URLEventUpdate,
+			ID:          sc.ID,
+			Biz:         sc.Biz,
+			ShortCode:   sc.ShortCode,
+			OriginalURL: sc.OriginalURL,
+			ExpireAt:    sc.ExpireAt,
 		})
+		if er != nil {
+			return nil, er
+		}
+
+		return []lmt.Messages{
+			{
+				ID:        id,
+				Biz:       sc.Biz,
+				MessageID: "upd-" + strconv.FormatInt(id, 10),
+				Topic:     generator.Topic,
+				Content:   string(content),
+				Status:    lmt.MessageStatusNotSend.Int(),
+			},
+		}, nil
 	}
 
-	return nil, nil
+	// 查询之后短链被并发删除时修改不会影响任何行，事务回滚，不发送变更消息
+	err = s.repo.Exec(ctx, sc.Biz, sc.ShortCode, fn)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.URLResponse{}, generator.ErrURLNotFound
+	}
+	if err != nil {
+		return domain.URLResponse{}, err
+	}
+
+	s.evictMapping(ctx, sc.ShortCode)
+
+	return domain.URLResponse{
+		ID:        sc.ID,
+		OriginURL: sc.OriginalURL,
+		ShortCode: sc.ShortCode,
+		ExpireAt:  sc.ExpireAt,
+	}, nil
 }
 
-func (s *Service) getID(ctx context.Context) (int64, error) {
-	counter := 0
-	for counter < RetryCounts {
-		counter++
-
-		select {
-		case <-ctx.Done():
-			return 0, ctx.Err()
-		case id, ok := <-s.idCh:
-			if !ok {
-				return 0, errors.New("ID通道已关闭")
+// DeleteURL 软删除短链，只有短链所属的业务才能删除。删除和删除事件在同一个本地消息表事务中完成，
+// 跳转服务消费事件后清理缓存。开启回收后非自定义的短码放入隔离区，隔离期结束后由回收任务归还到短码池
+func (s *Service) DeleteURL(ctx context.Context, req *intrv1.DelRequest) error {
+	sc, err := s.repo.GetByID(ctx, req.GetId())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return generator.ErrURLNotFound
+		}
+		return err
+	}
+
+	if sc.Biz != req.GetBiz() {
+		return generator.ErrURLForbidden
+	}
+
+	fn := func(ctx context.Context, d dao.ShortCodeInter) ([]lmt.Messages, error) {
+		er := d.Delete(ctx, sc.ID)
+		if er != nil {
+			return nil, er
+		}
+
+		id, er := s.getID(ctx)
+		if er != nil {
+			return nil, er
+		}
+
+		content, er := json.Marshal(event.URLEvent{
+			Type:      event.URLEventDelete,
+			ID:        sc.ID,
+			Biz:       sc.Biz,
+			ShortCode: sc.ShortCode,
+		})
+		if er != nil {
+			return nil, er
+		}
+
+		return []lmt.Messages{
+			{
+				ID:        id,
+				Biz:       sc.Biz,
+				MessageID: "del-" + strconv.FormatInt(id, 10),
+				Topic:     generator.Topic,
+				Content:   string(content),
+				Status:    lmt.MessageStatusNotSend.Int(),
+			},
+		}, nil
+	}
+
+	if err = s.repo.Exec(ctx, sc.Biz, sc.ShortCode, fn); err != nil {
+		return err
+	}
+
+	s.evictMapping(ctx, sc.ShortCode)
+
+	// 自定义短码是业务方指定的，与补偿处理器一致，不回收到短码池中分配给其他请求
+	if s.rc == nil || sc.Custom {
+		return nil
+	}
+
+	// 删除已经成功，隔离失败只会导致短码无法回收，不影响本次删除的结果
+	releaseAt := time.Now().Add(s.quarantine).UnixMilli()
+	if er := s.rc.Quarantine(ctx, releaseAt, sc.ShortCode); er != nil {
+		s.el.Error("短码放入隔离区失败",
+			elog.FieldErr(er),
+			elog.String("shortCode", sc.ShortCode))
+	}
+
+	return nil
+}
+
+// LookupByURL 原始URL没有参与分片计算，需要并发查询所有分表，再按照ID排序汇总，单个分表查询失败时整体失败
+func (s *Service) LookupByURL(ctx context.Context, req *intrv1.LookupByURLRequest) ([]domain.URLData, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		res      []domain.URLData
+	)
+	for _, d := range s.repo.Shards() {
+		wg.Add(1)
+		err := s.pool.Submit(func() {
+			defer wg.Done()
+			scs, er := d.GetByOriginalURL(ctx, req.GetBiz(), req.GetOriginalUrl())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if er != nil {
+				if firstErr == nil {
+					firstErr = er
+				}
+				return
+			}
+
+			for _, sc := range scs {
+				res = append(res, toURLData(sc))
 			}
-			return id, nil
+		})
+		if err != nil {
+			wg.Done()
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			break
 		}
 	}
 
-	return 0, errors.New("无可用ID")
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	slices.SortFunc(res, func(a, b domain.URLData) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return res, nil
+}
+
+func toURLData(sc dao.ShortCode) domain.URLData {
+	return domain.URLData{
+		ID:        sc.ID,
+		Biz:       sc.Biz,
+		OriginURL: sc.OriginalURL,
+		ShortCode: sc.ShortCode,
+		ExpireAt:  sc.ExpireAt,
+		Comment:   sc.Comment,
+		Creator:   sc.Creator,
+		Custom:    sc.Custom,
+		CreatedAt: sc.CreateTime,
+		UpdatedAt: sc.UpdateTime,
+	}
+}
+
+func (s *Service) getID(ctx context.Context) (int64, error) {
+	return receiveID(ctx, s.idCh)
+}
+
+// receiveID 从ID通道中获取一个ID，通道中没有ID时阻塞等待，直到ctx取消或通道关闭
+func receiveID(ctx context.Context, idCh <-chan int64) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case id, ok := <-idCh:
+		if !ok {
+			return 0, errors.New("ID通道已关闭")
+		}
+		return id, nil
+	}
 }
 
 // Handler 定义责任链处理短码生成的所有流程
@@ -186,25 +580,9 @@ func (i *IDHandler) Process(ctx context.Context, req *Request, resp *Response) e
 	}
 
 	// 获取分布式ID
-	var id int64
-	counter := 0
-	for counter < RetryCounts {
-		counter++
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case newID, ok := <-i.idCh:
-			if !ok {
-				return errors.New("ID通道已关闭")
-			}
-			id = newID
-			break
-		}
-	}
-
-	if id == 0 {
-		return errors.New("获取ID失败")
+	id, err := receiveID(ctx, i.idCh)
+	if err != nil {
+		return err
 	}
 
 	resp.ID = id
@@ -228,6 +606,11 @@ func (h *HashHandler) Process(ctx context.Context, req *Request, resp *Response)
 		return errors.New("短码验证处理器不存在")
 	}
 
+	// 自定义短码已经通过校验，不需要再计算哈希短码
+	if req.CustomCode != "" {
+		return h.next.Process(ctx, req, resp)
+	}
+
 	shortCode, err := h.hs.ShortenURL(req.OriginURL)
 	if err != nil {
 		return err
@@ -237,75 +620,130 @@ func (h *HashHandler) Process(ctx context.Context, req *Request, resp *Response)
 	return h.next.Process(ctx, req, resp)
 }
 
+// CollisionPolicy 哈希短码冲突时的处理策略
+type CollisionPolicy struct {
+	// 加盐重新计算哈希的最大次数，都冲突时才从短码池中获取
+	Salts int
+}
+
+func DefaultCollisionPolicy() CollisionPolicy {
+	return CollisionPolicy{
+		Salts: 3,
+	}
+}
+
 type ShortCodeHandler struct {
 	BaseHandler
-	cc cache.Cacher
-	db dao.ShortCodeInter
+	cc     cache.Cacher
+	hs     hs.Hasher
+	policy CollisionPolicy
 }
 
-func NewShortCodeHandler(cc cache.Cacher) Handler {
+func NewShortCodeHandler(cc cache.Cacher, hs hs.Hasher, policy CollisionPolicy) Handler {
 	return &ShortCodeHandler{
-		cc: cc,
+		cc:     cc,
+		hs:     hs,
+		policy: policy,
 	}
 }
 
-// Process 将短码放入到过滤器中查询是否存在，如果"可能存在"，即假阳性，则需要到数据库中进行二次确认
-// 如果不存在，则该短码为可用短码，反之则是重复短码，需要操作缓存从短码池中获取一条预生成的可用短码
+// Process 将短码放入到过滤器中查询是否存在，如果不存在，则该短码为可用短码，如果"可能存在"，
+// 则使用请求的ID作为盐重新计算哈希，多次加盐后仍然冲突才从短码池中获取一条预生成的可用短码，
+// 减少重复URL大量请求时对短码池的消耗，无论短码来自哪里都会继续执行后续的持久化处理器
 func (s *ShortCodeHandler) Process(ctx context.Context, req *Request, resp *Response) error {
 	if s.next == nil {
 		return errors.New("数据库处理器不存在")
 	}
 
-	if resp.ShortCode != "" {
-		res, err := s.cc.Exists(ctx, resp.ShortCode)
-		if err != nil {
-			if errors.Is(err, context.DeadlineExceeded) {
-				res, err = s.cc.Exists(ctx, resp.ShortCode)
-				if err != nil {
-					return err
-				}
-			} else {
-				return err
+	// 自定义短码已经确认未被占用，不需要从短码池中获取
+	if req.CustomCode != "" {
+		return s.next.Process(ctx, req, resp)
+	}
+
+	code, err := s.resolve(ctx, req, resp)
+	if err != nil {
+		return err
+	}
+
+	resp.ShortCode = code
+	return s.next.Process(ctx, req, resp)
+}
+
+// resolve 依次尝试哈希短码、加盐的哈希短码，都冲突时从短码池中获取
+func (s *ShortCodeHandler) resolve(ctx context.Context, req *Request, resp *Response) (string, error) {
+	code := resp.ShortCode
+	for i := 0; code != "" && i <= s.policy.Salts; i++ {
+		if i > 0 {
+			salted, err := s.hs.ShortenURL(salt(req.OriginURL, resp.ID, i))
+			if err != nil {
+				return "", err
 			}
+			code = salted
+			rehashCounter.Inc(req.Biz)
 		}
 
-		// hash计算后的短码可直接使用
-		if !res {
-			return nil
+		exists, err := s.exists(ctx, code)
+		if err != nil {
+			return "", err
+		}
+
+		if !exists {
+			// 哈希短码不在短码池中，需要写入过滤器，后续相同的短码才能识别为冲突
+			if err = s.cc.Add(ctx, cache.BFKey, code); err != nil {
+				return "", err
+			}
+			return code, nil
 		}
 	}
 
 	code, err := s.cc.GetShortCode(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		code, err = s.cc.GetShortCode(ctx)
+	}
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			code, err = s.cc.GetShortCode(ctx)
-			if err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+		return "", err
 	}
 
-	resp.ShortCode = code
+	poolFallbackCounter.Inc(req.Biz)
+	return code, nil
+}
 
-	return s.next.Process(ctx, req, resp)
+func (s *ShortCodeHandler) exists(ctx context.Context, code string) (bool, error) {
+	res, err := s.cc.Exists(ctx, code)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return s.cc.Exists(ctx, code)
+	}
+
+	return res, err
+}
+
+// salt 原始URL加盐，盐由请求ID和重试次数组成，保证不同请求的相同URL得到不同的短码
+func salt(url string, id int64, attempt int) string {
+	var builder strings.Builder
+	builder.WriteString(url)
+	builder.WriteString("#")
+	builder.WriteString(strconv.FormatInt(id, 10))
+	builder.WriteString("-")
+	builder.WriteString(strconv.Itoa(attempt))
+	return builder.String()
 }
 
 // DBHandler 数据库处理器
 type DBHandler struct {
 	BaseHandler
-	// 本地消息表机制
-	lt lmt.MessagePusher
-	d  dao.ShortCodeInter
+	// 分库分表的短码数据操作
+	repo repository.GeneratorRepository
 	// ID获取的通道
 	idCh <-chan int64
+	// 短码映射缓存，为nil时不清理
+	mc cache.MappingCache
 }
 
-func NewDBHandler(lt lmt.MessagePusher, idCh <-chan int64) Handler {
+func NewDBHandler(repo repository.GeneratorRepository, idCh <-chan int64, mc cache.MappingCache) Handler {
 	return &DBHandler{
-		lt:   lt,
+		repo: repo,
 		idCh: idCh,
+		mc:   mc,
 	}
 }
 
@@ -318,7 +756,8 @@ func (d *DBHandler) Process(ctx context.Context, req *Request, resp *Response) e
 
 	var err error
 	defer func() {
-		if err == nil {
+		// 短码已经被占用时不能归还到短码池，否则会再次分配出去
+		if err == nil || errors.Is(err, generator.ErrCustomCodeTaken) || errors.Is(err, gorm.ErrDuplicatedKey) {
 			return
 		}
 
@@ -329,32 +768,36 @@ func (d *DBHandler) Process(ctx context.Context, req *Request, resp *Response) e
 		}
 	}()
 
-	fn := func(ctx context.Context, tx *gorm.DB) ([]lmt.Messages, error) {
-		id, er := d.getID(ctx)
+	fn := func(ctx context.Context, sd dao.ShortCodeInter) ([]lmt.Messages, error) {
+		// 分库分表后自增主键会重复，主键使用全局唯一的分布式ID，ID处理器已经获取过时直接使用
+		if resp.ID == 0 {
+			id, er := d.getID(ctx)
+			if er != nil {
+				return nil, er
+			}
+			resp.ID = id
+		}
+		resp.ExpireAt = req.ExpireAt
+
+		er := sd.Insert(ctx, domain.URLData{
+			ID:        resp.ID,
+			Biz:       req.Biz,
+			OriginURL: req.OriginURL,
+			ShortCode: resp.ShortCode,
+			ExpireAt:  req.ExpireAt,
+			Comment:   req.Comment,
+			Creator:   req.Creator,
+			Custom:    req.CustomCode != "",
+		})
+		// 自定义短码已经被软删除的记录占用，或者并发请求同时通过了占用检查，由唯一索引兜底
+		if errors.Is(er, gorm.ErrDuplicatedKey) && req.CustomCode != "" {
+			return nil, generator.ErrCustomCodeTaken
+		}
 		if er != nil {
 			return nil, er
 		}
 
-		now := time.Now().UnixMilli()
-		expireAt := now + time.Duration(req.Expiration).Milliseconds()
-		resp.ExpireAt = expireAt
-		resp.ID = id
-
-		er = tx.WithContext(ctx).Model(&dao.ShortCode{}).
-			Create(&dao.ShortCode{
-				OriginalURL: req.OriginURL,
-				ShortCode:   resp.ShortCode,
-				ExpireAt:    expireAt,
-				Comment:     req.Comment,
-				Creator:     req.Creator,
-				CreateTime:  now,
-				UpdateTime:  now,
-			}).Error
-		if err != nil {
-			return nil, err
-		}
-
-		id, er = d.getID(ctx)
+		id, er := d.getID(ctx)
 		if er != nil {
 			return nil, er
 		}
@@ -375,32 +818,26 @@ func (d *DBHandler) Process(ctx context.Context, req *Request, resp *Response) e
 		}, nil
 	}
 
-	err = d.lt.ExecTo(ctx, fn, resp.ShortCode)
+	err = d.repo.Exec(ctx, req.Biz, resp.ShortCode, fn)
+	if err == nil {
+		d.evictMissing(ctx, resp.ShortCode)
+	}
 	return err
 }
 
-func (d *DBHandler) getID(ctx context.Context) (int64, error) {
-	counter := 0
-	var id int64
-	for counter < RetryCounts {
-		counter++
-
-		select {
-		case <-ctx.Done():
-			return 0, ctx.Err()
-		case newID, ok := <-d.idCh:
-			if !ok {
-				return 0, errors.New("ID通道已关闭")
-			}
-			id = newID
-			break
-		}
+// evictMissing 短码写入后清理解析时缓存的不存在记录，清理失败时缓存在过期后自然失效
+func (d *DBHandler) evictMissing(ctx context.Context, code string) {
+	if d.mc == nil {
+		return
 	}
-	if id == 0 {
-		return 0, errors.New("获取ID失败")
+
+	if err := d.mc.Del(ctx, code); err != nil {
+		elog.DefaultLogger.Warn("清理短码映射缓存失败", elog.FieldErr(err), elog.String("shortCode", code))
 	}
+}
 
-	return id, nil
+func (d *DBHandler) getID(ctx context.Context) (int64, error) {
+	return receiveID(ctx, d.idCh)
 }
 
 // CompensateHandler 补偿处理器
@@ -416,7 +853,14 @@ func NewCompensateHandler(cc cache.Cacher) Handler {
 }
 
 func (c *CompensateHandler) Process(ctx context.Context, req *Request, resp *Response) error {
-	return c.cc.InsertShortCode(ctx, resp.ShortCode)
+	// 自定义短码是业务方指定的，不能归还到短码池中分配给其他请求
+	if req.CustomCode != "" {
+		return nil
+	}
+
+	err := c.cc.InsertShortCode(ctx, resp.ShortCode)
+	compensationCounter.Inc(req.Biz, resultLabel(err))
+	return err
 }
 
 type Response struct {
@@ -424,6 +868,8 @@ type Response struct {
 	OriginURL string
 	ShortCode string
 	ExpireAt  int64
+	// 结果来自已有的短链，不需要重新写入映射缓存
+	Replayed bool
 }
 
 type Request struct {
@@ -432,5 +878,8 @@ type Request struct {
 	OriginURL  string
 	CustomCode string
 	Comment    string
-	Expiration int
+	// 过期时间的毫秒时间戳，0表示永不过期
+	ExpireAt int64
+	// 客户端幂等键
+	IdempotencyKey string
 }
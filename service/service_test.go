@@ -0,0 +1,425 @@
+// Copyright 2025 TimeWtr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TimeWtr/generator"
+	intrv1 "github.com/TimeWtr/generator/api/proto/gen/intr.v1"
+	"github.com/TimeWtr/generator/domain"
+	"github.com/TimeWtr/generator/event"
+	"github.com/TimeWtr/generator/internal/testutil"
+	"github.com/TimeWtr/generator/repository"
+	"github.com/TimeWtr/generator/repository/cache/recycle"
+	"github.com/TimeWtr/generator/repository/dao"
+	"github.com/panjf2000/ants/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+)
+
+// newTestRepo 使用单个sqlite分表的短码数据操作
+func newTestRepo(t *testing.T) (repository.GeneratorRepository, dao.ShortCodeInter) {
+	f, dbs := testutil.NewShards(t, 1)
+	return repository.NewGeneratorRepository(f, testutil.Pusher), dao.NewShardShortCodeDao(dbs[0], "short_code_0")
+}
+
+// newRecordedRepo 使用单个sqlite分表，并记录本地消息表中已经提交的消息
+func newRecordedRepo(t *testing.T) (repository.GeneratorRepository, dao.ShortCodeInter, *testutil.Recorder) {
+	f, dbs := testutil.NewShards(t, 1)
+	rec := &testutil.Recorder{}
+	return repository.NewGeneratorRepository(f, rec.Pusher), dao.NewShardShortCodeDao(dbs[0], "short_code_0"), rec
+}
+
+// events 解析已经提交的短链变更事件
+func events(t *testing.T, rec *testutil.Recorder) []event.URLEvent {
+	var res []event.URLEvent
+	for _, msg := range rec.Messages() {
+		var evt event.URLEvent
+		require.NoError(t, json.Unmarshal([]byte(msg.Content), &evt))
+		res = append(res, evt)
+	}
+	return res
+}
+
+// racingRepo 查询到短链之后立即删除，模拟查询和修改之间的并发删除
+type racingRepo struct {
+	repository.GeneratorRepository
+	d dao.ShortCodeInter
+}
+
+func (r *racingRepo) GetByID(ctx context.Context, id int64) (dao.ShortCode, error) {
+	sc, err := r.GeneratorRepository.GetByID(ctx, id)
+	if err != nil {
+		return sc, err
+	}
+	return sc, r.d.Delete(ctx, id)
+}
+
+// recordHandler 记录是否被调用的处理器
+type recordHandler struct {
+	BaseHandler
+	called bool
+}
+
+func (r *recordHandler) Process(context.Context, *Request, *Response) error {
+	r.called = true
+	return nil
+}
+
+// memCacher 内存中的短码池和过滤器
+type memCacher struct {
+	mu   sync.Mutex
+	pool []string
+	bf   map[string]bool
+}
+
+func newMemCacher(codes ...string) *memCacher {
+	return &memCacher{pool: codes, bf: make(map[string]bool)}
+}
+
+func (m *memCacher) Count(context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.pool)), nil
+}
+
+func (m *memCacher) GetShortCode(context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.pool) == 0 {
+		return "", errors.New("短码池为空")
+	}
+
+	code := m.pool[0]
+	m.pool = m.pool[1:]
+	return code, nil
+}
+
+func (m *memCacher) InsertShortCode(_ context.Context, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pool = append(m.pool, code)
+	return nil
+}
+
+func (m *memCacher) BatchInsertShortCodes(_ context.Context, codes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pool = append(m.pool, codes...)
+	return nil
+}
+
+func (m *memCacher) Reserve(context.Context, string) error { return nil }
+
+func (m *memCacher) Add(_ context.Context, _ string, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bf[fmt.Sprint(data)] = true
+	return nil
+}
+
+func (m *memCacher) MAdd(ctx context.Context, key string, data []any) error {
+	for _, d := range data {
+		_ = m.Add(ctx, key, d)
+	}
+	return nil
+}
+
+func (m *memCacher) Exists(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bf[key], nil
+}
+
+func (m *memCacher) MExists(_ context.Context, _ string, data []any) (map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make(map[string]bool, len(data))
+	for _, d := range data {
+		res[fmt.Sprint(d)] = m.bf[fmt.Sprint(d)]
+	}
+	return res, nil
+}
+
+func TestDBHandler_CustomCodeTaken(t *testing.T) {
+	repo, d := newTestRepo(t)
+	ctx := context.Background()
+
+	// 软删除的自定义短码在清理前仍然占用唯一索引
+	require.NoError(t, d.Insert(ctx, domain.URLData{ID: 100, Biz: "marketing", ShortCode: "spring-sale", Custom: true}))
+	require.NoError(t, d.Delete(ctx, 100))
+
+	h := NewDBHandler(repo, testutil.NewIDCh(t), nil)
+	next := &recordHandler{}
+	h.Next(next)
+
+	err := h.Process(ctx, &Request{Biz: "marketing", CustomCode: "spring-sale"}, &Response{ShortCode: "spring-sale"})
+	assert.ErrorIs(t, err, generator.ErrCustomCodeTaken)
+	assert.False(t, next.called)
+
+	err = h.Process(ctx, &Request{Biz: "marketing", OriginURL: "https://example.com"}, &Response{ShortCode: "abc"})
+	require.NoError(t, err)
+	sc, err := d.GetURLByShortCode(ctx, "abc")
+	require.NoError(t, err)
+	assert.False(t, sc.Custom)
+}
+
+func TestDBHandler_Compensate(t *testing.T) {
+	repo, d := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, d.Insert(ctx, domain.URLData{ID: 100, Biz: "marketing", ShortCode: "abc"}))
+
+	h := NewDBHandler(repo, testutil.NewIDCh(t), nil)
+	next := &recordHandler{}
+	h.Next(next)
+
+	// 短码已经持久化，归还到短码池会被重复分配
+	err := h.Process(ctx, &Request{Biz: "marketing", OriginURL: "https://example.com"}, &Response{ShortCode: "abc"})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	assert.False(t, next.called)
+
+	// 其他原因写入失败时归还短码
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = h.Process(ctx, &Request{Biz: "marketing", OriginURL: "https://example.com"}, &Response{ShortCode: "def"})
+	assert.Error(t, err)
+	assert.True(t, next.called)
+}
+
+func TestService_LookupByURL(t *testing.T) {
+	f, _ := testutil.NewShards(t, 3)
+	repo := repository.NewGeneratorRepository(f, testutil.Pusher)
+	pool, err := ants.NewPool(4)
+	require.NoError(t, err)
+	t.Cleanup(pool.Release)
+	s := NewService(testutil.NewIDCh(t), repo, nil, pool)
+	ctx := context.Background()
+
+	url := "https://example.com/a"
+	var data []domain.URLData
+	for i := 1; i <= 6; i++ {
+		biz := "marketing"
+		if i%2 == 0 {
+			biz = "other"
+		}
+		data = append(data, domain.URLData{ID: int64(i), Biz: biz, OriginURL: url, ShortCode: fmt.Sprintf("code%d", i)})
+	}
+	data = append(data, domain.URLData{ID: 7, Biz: "marketing", OriginURL: "https://example.com/b", ShortCode: "code7"})
+	require.NoError(t, repo.BatchInsert(ctx, data))
+	for _, d := range repo.Shards() {
+		require.NoError(t, d.Delete(ctx, 5))
+	}
+
+	ids := func(res []domain.URLData) []int64 {
+		var ids []int64
+		for _, r := range res {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	// 汇总所有分表的结果并按照ID排序，不包含已经删除的短链
+	res, err := s.LookupByURL(ctx, &intrv1.LookupByURLRequest{OriginalUrl: url})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 6}, ids(res))
+
+	res, err = s.LookupByURL(ctx, &intrv1.LookupByURLRequest{Biz: "marketing", OriginalUrl: url})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 3}, ids(res))
+
+	res, err = s.LookupByURL(ctx, &intrv1.LookupByURLRequest{OriginalUrl: "https://example.com/none"})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestService_UpdateURL(t *testing.T) {
+	repo, d, rec := newRecordedRepo(t)
+	s := NewService(testutil.NewIDCh(t), repo, nil, nil)
+	ctx := context.Background()
+	require.NoError(t, d.Insert(ctx, domain.URLData{
+		ID: 100, Biz: "marketing", OriginURL: "https://example.com/a", ShortCode: "abc", Comment: "old",
+	}))
+
+	update := func(biz string, id int64, meta *intrv1.Metadata) (domain.URLResponse, error) {
+		return s.UpdateURL(ctx, &intrv1.UpdateURLRequest{Biz: biz, Id: id, Meta: meta})
+	}
+
+	// 只有短链所属的业务才能修改
+	_, err := update("other", 100, &intrv1.Metadata{OriginalUrl: "https://evil.com"})
+	assert.ErrorIs(t, err, generator.ErrURLForbidden)
+	_, err = update("marketing", 999, &intrv1.Metadata{OriginalUrl: "https://example.com/b"})
+	assert.ErrorIs(t, err, generator.ErrURLNotFound)
+	assert.Empty(t, rec.Messages())
+
+	res, err := update("marketing", 100, &intrv1.Metadata{OriginalUrl: "https://example.com/b"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.URLResponse{ID: 100, OriginURL: "https://example.com/b", ShortCode: "abc"}, res)
+
+	// 未传递的字段保持不变
+	expireAt := time.Now().Add(time.Hour).UnixMilli()
+	res, err = update("marketing", 100, &intrv1.Metadata{
+		Comment: "new",
+		Expiry:  &intrv1.Metadata_ExpireAt{ExpireAt: expireAt},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", res.OriginURL)
+
+	sc, err := d.GetURLByShortCode(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", sc.OriginalURL)
+	assert.Equal(t, domain.HashURL("https://example.com/b"), sc.URLHash)
+	assert.Equal(t, "new", sc.Comment)
+	assert.Equal(t, expireAt, sc.ExpireAt)
+
+	// 每次修改在同一个事务中写入变更消息
+	assert.Equal(t, []event.URLEvent{
+		{Type: event.URLEventUpdate, ID: 100, Biz: "marketing", ShortCode: "abc", OriginalURL: "https://example.com/b"},
+		{Type: event.URLEventUpdate, ID: 100, Biz: "marketing", ShortCode: "abc", OriginalURL: "https://example.com/b", ExpireAt: expireAt},
+	}, events(t, rec))
+}
+
+func TestService_UpdateURL_Deleted(t *testing.T) {
+	repo, d, rec := newRecordedRepo(t)
+	ctx := context.Background()
+	require.NoError(t, d.Insert(ctx, domain.URLData{ID: 100, Biz: "marketing", OriginURL: "https://example.com/a", ShortCode: "abc"}))
+
+	// 查询之后被并发删除，修改不影响任何行，不发送变更消息
+	s := NewService(testutil.NewIDCh(t), &racingRepo{GeneratorRepository: repo, d: d}, nil, nil)
+	_, err := s.UpdateURL(ctx, &intrv1.UpdateURLRequest{
+		Biz: "marketing", Id: 100, Meta: &intrv1.Metadata{OriginalUrl: "https://example.com/b"},
+	})
+	assert.ErrorIs(t, err, generator.ErrURLNotFound)
+	assert.Empty(t, rec.Messages())
+
+	// 已经删除的短链
+	s = NewService(testutil.NewIDCh(t), repo, nil, nil)
+	_, err = s.UpdateURL(ctx, &intrv1.UpdateURLRequest{
+		Biz: "marketing", Id: 100, Meta: &intrv1.Metadata{OriginalUrl: "https://example.com/b"},
+	})
+	assert.ErrorIs(t, err, generator.ErrURLNotFound)
+}
+
+func TestService_BatchGenerateURL(t *testing.T) {
+	repo, d, rec := newRecordedRepo(t)
+	pool, err := ants.NewPool(4)
+	require.NoError(t, err)
+	t.Cleanup(pool.Release)
+	s := NewService(testutil.NewIDCh(t), repo, newMemCacher(), pool)
+	ctx := context.Background()
+	require.NoError(t, d.Insert(ctx, domain.URLData{ID: 100, Biz: "marketing", ShortCode: "taken-code", Custom: true}))
+
+	res, err := s.BatchGenerateURL(ctx, &intrv1.BatchURLRequest{
+		Biz:     "marketing",
+		Creator: "alice",
+		Meta: []*intrv1.Metadata{
+			{OriginalUrl: "https://example.com/a"},
+			{OriginalUrl: "https://example.com/b", CustomCode: proto.String("spring-sale")},
+			{OriginalUrl: "https://example.com/c", CustomCode: proto.String("ab")},
+			{OriginalUrl: "https://example.com/d", CustomCode: proto.String("taken-code")},
+			{OriginalUrl: "https://example.com/e", Expiry: &intrv1.Metadata_ExpireAt{ExpireAt: 1}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+
+	// 结果与请求的顺序一一对应，单条失败不影响其他URL
+	for i, url := range []string{"https://example.com/a", "https://example.com/b"} {
+		require.NoError(t, res[i].Err)
+		assert.Equal(t, url, res[i].OriginURL)
+		sc, er := d.GetURLByShortCode(ctx, res[i].ShortCode)
+		require.NoError(t, er)
+		assert.Equal(t, res[i].ID, sc.ID)
+		assert.Equal(t, url, sc.OriginalURL)
+		assert.Equal(t, "alice", sc.Creator)
+	}
+	assert.Equal(t, "spring-sale", res[1].ShortCode)
+	assert.ErrorIs(t, res[2].Err, generator.ErrCustomCodeInvalid)
+	assert.ErrorIs(t, res[3].Err, generator.ErrCustomCodeTaken)
+	assert.ErrorIs(t, res[4].Err, generator.ErrExpirationInvalid)
+	for _, r := range res[2:] {
+		assert.Zero(t, r.URLResponse)
+	}
+	assert.Len(t, rec.Messages(), 2)
+
+	// ctx取消后不再提交任务，每条URL都记录取消的原因
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	res, err = s.BatchGenerateURL(ctx, &intrv1.BatchURLRequest{
+		Biz:  "marketing",
+		Meta: []*intrv1.Metadata{{OriginalUrl: "https://example.com/f"}, {OriginalUrl: "https://example.com/g"}},
+	})
+	require.NoError(t, err)
+	for _, r := range res {
+		assert.ErrorIs(t, r.Err, context.Canceled)
+	}
+	assert.Len(t, rec.Messages(), 2)
+}
+
+func TestService_DeleteURL(t *testing.T) {
+	f, dbs := testutil.NewShards(t, 1)
+	rec := &testutil.Recorder{}
+	repo := repository.NewGeneratorRepository(f, rec.Pusher)
+	d := dao.NewShardShortCodeDao(dbs[0], "short_code_0")
+	_, client := testutil.NewRedis(t)
+	rc := recycle.NewCacheRecycle(client)
+	s := NewService(testutil.NewIDCh(t), repo, nil, nil, WithRecycle(rc, time.Hour))
+	ctx := context.Background()
+	require.NoError(t, d.Insert(ctx, domain.URLData{ID: 100, Biz: "marketing", OriginURL: "https://example.com/a", ShortCode: "abc"}))
+	require.NoError(t, d.Insert(ctx, domain.URLData{ID: 101, Biz: "marketing", OriginURL: "https://example.com/b", ShortCode: "spring-sale", Custom: true}))
+
+	// 只有短链所属的业务才能删除
+	err := s.DeleteURL(ctx, &intrv1.DelRequest{Biz: "other", Id: 100})
+	assert.ErrorIs(t, err, generator.ErrURLForbidden)
+	err = s.DeleteURL(ctx, &intrv1.DelRequest{Biz: "marketing", Id: 999})
+	assert.ErrorIs(t, err, generator.ErrURLNotFound)
+	assert.Empty(t, rec.Messages())
+
+	require.NoError(t, s.DeleteURL(ctx, &intrv1.DelRequest{Biz: "marketing", Id: 100}))
+	require.NoError(t, s.DeleteURL(ctx, &intrv1.DelRequest{Biz: "marketing", Id: 101}))
+
+	// 软删除后查询不到，记录仍然保留并占用短码
+	_, err = repo.GetByID(ctx, 100)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	err = s.DeleteURL(ctx, &intrv1.DelRequest{Biz: "marketing", Id: 100})
+	assert.ErrorIs(t, err, generator.ErrURLNotFound)
+	var deleted []dao.ShortCode
+	require.NoError(t, dbs[0].Table("short_code_0").Order("id").Find(&deleted).Error)
+	require.Len(t, deleted, 2)
+	for _, sc := range deleted {
+		assert.NotZero(t, sc.DeleteTime)
+	}
+
+	assert.Equal(t, []event.URLEvent{
+		{Type: event.URLEventDelete, ID: 100, Biz: "marketing", ShortCode: "abc"},
+		{Type: event.URLEventDelete, ID: 101, Biz: "marketing", ShortCode: "spring-sale"},
+	}, events(t, rec))
+
+	// 只有非自定义的短码进入隔离区，隔离期结束前不能回收
+	codes, err := rc.Release(ctx, time.Now().UnixMilli(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, codes)
+	codes, err = rc.Release(ctx, time.Now().Add(2*time.Hour).UnixMilli(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, codes)
+}